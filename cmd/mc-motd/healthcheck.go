@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/itzg/go-flagsfiller"
+	"github.com/wroud/mc-motd/server"
+)
+
+// healthcheckTimeout bounds the request to the readiness endpoint
+const healthcheckTimeout = 5 * time.Second
+
+// HealthcheckConfig configures the healthcheck subcommand, which shares the PORT, HTTP_ADDRESS
+// and PROXY_PROTOCOL_ environment variables with the server
+type HealthcheckConfig struct {
+	Port    int    `default:"25565" usage:"The [port] the server listens on"`
+	Address string `usage:"The host:port [address] to status ping instead of the local port"`
+	Http    struct {
+		Address string `usage:"The [address] of the HTTP listener, whose /readyz endpoint is checked instead of status pinging"`
+	}
+	ProxyProtocol server.ProxyProtocolConfig `usage:"PROXY protocol configuration of the server, sending a header if it expects one from localhost"`
+}
+
+// healthcheck checks the readiness endpoint if there is an HTTP listener, otherwise it status
+// pings the local listener. It returns the exit code, which is non-zero if the check failed,
+// for use as a Docker HEALTHCHECK in images without a shell.
+func healthcheck(args []string) int {
+	var config HealthcheckConfig
+	flagSet := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	if err := flagsfiller.New(flagsfiller.WithEnv("")).Fill(flagSet, &config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	_ = flagSet.Parse(args)
+
+	if config.Address == "" && config.Http.Address != "" {
+		if err := checkReady(config.Http.Address); err != nil {
+			fmt.Fprintf(os.Stderr, "Readiness check failed: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	address := config.Address
+	if address == "" {
+		address = net.JoinHostPort("localhost", strconv.Itoa(config.Port))
+	}
+
+	if err := server.PingHealthcheck(context.Background(), address, &config.ProxyProtocol); err != nil {
+		fmt.Fprintf(os.Stderr, "Status ping of %s failed: %v\n", address, err)
+		return 1
+	}
+	return 0
+}
+
+// checkReady requests /readyz from the HTTP listener, connecting to localhost if it listens on
+// all addresses
+func checkReady(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid HTTP address %s: %w", address, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
+	client := &http.Client{Timeout: healthcheckTimeout}
+	resp, err := client.Get("http://" + net.JoinHostPort(host, port) + "/readyz")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("/readyz responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
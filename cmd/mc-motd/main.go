@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/itzg/go-flagsfiller"
 	"github.com/sirupsen/logrus"
@@ -18,19 +19,26 @@ var (
 	date    = "unknown"
 )
 
+const configWatchInterval = 5 * time.Second
+
 func showVersion() {
 	fmt.Printf("%v, commit %v, built at %v", version, commit, date)
 }
 
 type CliConfig struct {
-	Version bool `usage:"Output version and exit"`
-	Debug   bool `usage:"Enable debug logs"`
-	Trace   bool `usage:"Enable trace logs"`
+	Version bool   `usage:"Output version and exit"`
+	Debug   bool   `usage:"Enable debug logs"`
+	Trace   bool   `usage:"Enable trace logs"`
+	Config  string `usage:"Path to a YAML, TOML or JSON [file] declaring the server configuration. Its values take precedence over flags and it is re-read on SIGHUP or when the file changes"`
 
 	ServerConfig server.Config `flatten:"true"`
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "healthcheck" {
+		os.Exit(healthcheck(os.Args[2:]))
+	}
+
 	var cliConfig CliConfig
 	err := flagsfiller.Parse(&cliConfig, flagsfiller.WithEnv(""))
 	if err != nil {
@@ -56,11 +64,30 @@ func main() {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	s, err := server.NewServer(ctx, &cliConfig.ServerConfig)
+	serverConfig := &cliConfig.ServerConfig
+	var configLoader *server.ConfigLoader
+	if cliConfig.Config != "" {
+		configLoader, err = server.NewConfigLoader(cliConfig.Config, serverConfig)
+		if err != nil {
+			logrus.WithError(err).Fatal("Could not setup configuration file loading")
+		}
+
+		serverConfig, err = configLoader.Load()
+		if err != nil {
+			logrus.WithError(err).WithField("file", cliConfig.Config).Fatal("Could not load configuration file")
+		}
+	}
+
+	s, err := server.NewServer(ctx, serverConfig)
 	if err != nil {
 		logrus.WithError(err).Fatal("Could not setup server")
 	}
 
+	configChanged := make(chan struct{}, 1)
+	if configLoader != nil {
+		go configLoader.Watch(ctx, configWatchInterval, configChanged)
+	}
+
 	go s.Run()
 
 	for {
@@ -68,6 +95,9 @@ func main() {
 		case <-s.Done():
 			return
 
+		case <-configChanged:
+			reloadConfig(s, configLoader)
+
 		case sig := <-signals:
 			switch sig {
 
@@ -75,9 +105,29 @@ func main() {
 				cancel()
 				// but wait for the server to be done
 
+			case syscall.SIGHUP:
+				if configLoader == nil {
+					logrus.Warn("Received SIGHUP, but no configuration file was given to reload")
+				} else {
+					reloadConfig(s, configLoader)
+				}
+
 			default:
 				logrus.WithField("signal", sig).Warn("Received unexpected signal")
 			}
 		}
 	}
 }
+
+func reloadConfig(s *server.Server, configLoader *server.ConfigLoader) {
+	config, err := configLoader.Load()
+	if err == nil {
+		err = s.Reload(config)
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Could not reload configuration, keeping the current one")
+		return
+	}
+
+	logrus.Info("Reloaded configuration")
+}
@@ -0,0 +1,291 @@
+// Package configfile decodes YAML, TOML and JSON configuration files into configuration structs.
+// Setting names are matched case-insensitively, ignoring dashes and underscores, so that a field
+// named ServerStatus can be given as server-status, server_status or serverStatus.
+package configfile
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ElementTyper is implemented by map types that keep their values undecoded, such as
+// map[string]json.RawMessage, to declare the type their values should be checked against.
+type ElementTyper interface {
+	ConfigElementType() reflect.Type
+}
+
+// Parse parses the configuration file content in the given format, which is one of
+// yaml, yml, toml or json.
+func Parse(format string, data []byte) (map[string]any, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "yaml", "yml":
+		var result map[string]any
+		if err := yaml.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = map[string]any{}
+		}
+		return result, nil
+	case "toml":
+		var result map[string]any
+		if err := toml.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		// Arrays of tables are decoded as []map[string]any rather than []any
+		return tomlTables(result).(map[string]any), nil
+	case "json":
+		var result map[string]any
+		decoder := json.NewDecoder(strings.NewReader(string(data)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&result); err != nil {
+			return nil, errors.Wrap(err, "json")
+		}
+		return result, nil
+	default:
+		return nil, errors.Errorf("unsupported configuration file format %q", format)
+	}
+}
+
+// tomlTables converts the arrays of tables in a decoded TOML value to lists of values
+func tomlTables(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, entry := range v {
+			v[key] = tomlTables(entry)
+		}
+		return v
+	case []map[string]any:
+		result := make([]any, len(v))
+		for i, table := range v {
+			result[i] = tomlTables(table)
+		}
+		return result
+	case []any:
+		for i, item := range v {
+			v[i] = tomlTables(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// Load reads the file at path, choosing the format by file extension, and decodes it over the
+// values already present in target, which must be a pointer to a struct.
+func Load(path string, target any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	values, err := Parse(filepath.Ext(path), data)
+	if err != nil {
+		return err
+	}
+
+	return Decode(values, target)
+}
+
+// Decode decodes parsed configuration values over the values already present in target,
+// which must be a pointer to a struct. Unknown settings are reported as errors.
+func Decode(values map[string]any, target any) error {
+	targetType := reflect.TypeOf(target)
+	if targetType.Kind() != reflect.Pointer || targetType.Elem().Kind() != reflect.Struct {
+		return errors.New("configuration target must be a pointer to a struct")
+	}
+
+	normalized, err := normalize(values, targetType.Elem(), "")
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+var (
+	durationType         = reflect.TypeOf(time.Duration(0))
+	elementTyperType     = reflect.TypeOf((*ElementTyper)(nil)).Elem()
+	jsonUnmarshalerType  = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	stringInterfaceTypes = []reflect.Type{jsonUnmarshalerType, textUnmarshalerType}
+)
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+}
+
+// normalize rewrites the parsed value so that encoding/json can decode it into the given type:
+// keys are renamed to the matching field names and scalars are converted where needed.
+func normalize(value any, t reflect.Type, path string) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	if reflect.PointerTo(t).Implements(elementTyperType) {
+		entries, ok := value.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("%s: expected a table of entries", path)
+		}
+		elemType := reflect.New(t).Interface().(ElementTyper).ConfigElementType()
+		result := make(map[string]any, len(entries))
+		for key, entry := range entries {
+			normalizedEntry, err := normalize(entry, elemType, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = normalizedEntry
+		}
+		return result, nil
+	}
+
+	if t == durationType {
+		if text, ok := value.(string); ok {
+			d, err := time.ParseDuration(text)
+			if err != nil {
+				return nil, errors.Errorf("%s: %v", path, err)
+			}
+			return int64(d), nil
+		}
+		return value, nil
+	}
+
+	for _, iface := range stringInterfaceTypes {
+		if reflect.PointerTo(t).Implements(iface) && t.Kind() != reflect.Struct {
+			return value, nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		entries, ok := value.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("%s: expected a table of settings", path)
+		}
+		result := make(map[string]any, len(entries))
+		for key, entry := range entries {
+			field, found := findField(t, key)
+			if !found {
+				return nil, errors.Errorf("unknown setting %q", joinPath(path, key))
+			}
+			normalizedEntry, err := normalize(entry, field.Type, joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[jsonName(field)] = normalizedEntry
+		}
+		return result, nil
+
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			// Allow a single value where a list is expected
+			if _, isList := value.([]any); !isList {
+				value = []any{value}
+			}
+		}
+		items, ok := value.([]any)
+		if !ok {
+			return nil, errors.Errorf("%s: expected a list", path)
+		}
+		result := make([]any, len(items))
+		for i, item := range items {
+			normalizedItem, err := normalize(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			result[i] = normalizedItem
+		}
+		return result, nil
+
+	case reflect.Map:
+		entries, ok := value.(map[string]any)
+		if !ok {
+			return nil, errors.Errorf("%s: expected a table", path)
+		}
+		result := make(map[string]any, len(entries))
+		for key, entry := range entries {
+			normalizedEntry, err := normalize(entry, t.Elem(), joinPath(path, key))
+			if err != nil {
+				return nil, err
+			}
+			result[key] = normalizedEntry
+		}
+		return result, nil
+
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case json.Number, int, int64, float64, bool:
+			return fmt.Sprint(v), nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		case fmt.Stringer:
+			// TOML local dates and times
+			return v.String(), nil
+		default:
+			return nil, errors.Errorf("%s: expected a string", path)
+		}
+
+	default:
+		return value, nil
+	}
+}
+
+// findField looks up the field matching the setting name, including fields promoted
+// from embedded structs
+func findField(t reflect.Type, name string) (reflect.StructField, bool) {
+	wanted := normalizeName(name)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || jsonName(field) == "-" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if _, tagged := field.Tag.Lookup("json"); !tagged {
+				if found, ok := findField(field.Type, name); ok {
+					return found, true
+				}
+				continue
+			}
+		}
+		if normalizeName(jsonName(field)) == wanted {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func jsonName(field reflect.StructField) string {
+	if tag, ok := field.Tag.Lookup("json"); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
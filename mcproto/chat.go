@@ -0,0 +1,511 @@
+package mcproto
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// TextComponent is a chat component as declared at https://minecraft.wiki/w/Text_component_format
+// It is used for the server list description and disconnect reasons.
+type TextComponent struct {
+	Text          string           `json:"text,omitempty"`
+	Translate     string           `json:"translate,omitempty"`
+	With          []*TextComponent `json:"with,omitempty"`
+	Color         string           `json:"color,omitempty"`
+	Bold          *bool            `json:"bold,omitempty"`
+	Italic        *bool            `json:"italic,omitempty"`
+	Underlined    *bool            `json:"underlined,omitempty"`
+	Strikethrough *bool            `json:"strikethrough,omitempty"`
+	Obfuscated    *bool            `json:"obfuscated,omitempty"`
+	Extra         []*TextComponent `json:"extra,omitempty"`
+}
+
+// MarshalJSON ensures that components without translate key always carry a text field,
+// since the client rejects components that have neither.
+func (c *TextComponent) MarshalJSON() ([]byte, error) {
+	type plain TextComponent
+	if c.Translate != "" {
+		return json.Marshal((*plain)(c))
+	}
+	return json.Marshal(&struct {
+		Text string `json:"text"`
+		*plain
+	}{
+		Text:  c.Text,
+		plain: (*plain)(c),
+	})
+}
+
+// NewTextComponent creates an unstyled component containing the given text
+func NewTextComponent(text string) *TextComponent {
+	return &TextComponent{Text: text}
+}
+
+// PlainText returns the text content of the component and all of its children without styling
+func (c *TextComponent) PlainText() string {
+	var sb strings.Builder
+	c.writePlainText(&sb)
+	return sb.String()
+}
+
+func (c *TextComponent) writePlainText(sb *strings.Builder) {
+	if c == nil {
+		return
+	}
+	if c.Translate != "" {
+		sb.WriteString(c.Translate)
+	} else {
+		sb.WriteString(c.Text)
+	}
+	for _, child := range c.Extra {
+		child.writePlainText(sb)
+	}
+}
+
+// LegacyText returns the text content of the component and all of its children using legacy
+// § formatting codes. Hex colors have no legacy equivalent and are dropped.
+func (c *TextComponent) LegacyText() string {
+	var sb strings.Builder
+	var previous TextComponent
+	c.writeLegacyText(&sb, TextComponent{}, &previous)
+	return sb.String()
+}
+
+func (c *TextComponent) writeLegacyText(sb *strings.Builder, inherited TextComponent, previous *TextComponent) {
+	if c == nil {
+		return
+	}
+
+	style := inherited
+	if c.Color != "" {
+		style.Color = c.Color
+	}
+	for _, pair := range []struct {
+		dst **bool
+		src *bool
+	}{
+		{&style.Bold, c.Bold},
+		{&style.Italic, c.Italic},
+		{&style.Underlined, c.Underlined},
+		{&style.Strikethrough, c.Strikethrough},
+		{&style.Obfuscated, c.Obfuscated},
+	} {
+		if pair.src != nil {
+			*pair.dst = pair.src
+		}
+	}
+
+	text := c.Text
+	if c.Translate != "" {
+		text = c.Translate
+	}
+	if text != "" {
+		if !sameLegacyStyle(&style, previous) {
+			writeLegacyStyle(sb, &style, previous)
+			*previous = style
+		}
+		sb.WriteString(text)
+	}
+
+	for _, child := range c.Extra {
+		child.writeLegacyText(sb, style, previous)
+	}
+}
+
+func isSet(v *bool) bool {
+	return v != nil && *v
+}
+
+func sameLegacyStyle(a, b *TextComponent) bool {
+	return legacyColorCode(a.Color) == legacyColorCode(b.Color) &&
+		isSet(a.Bold) == isSet(b.Bold) &&
+		isSet(a.Italic) == isSet(b.Italic) &&
+		isSet(a.Underlined) == isSet(b.Underlined) &&
+		isSet(a.Strikethrough) == isSet(b.Strikethrough) &&
+		isSet(a.Obfuscated) == isSet(b.Obfuscated)
+}
+
+func writeLegacyStyle(sb *strings.Builder, style *TextComponent, previous *TextComponent) {
+	// Color codes reset formatting, so start over with either the color or a reset
+	if code := legacyColorCode(style.Color); code != 0 {
+		sb.WriteRune(legacyFormattingChar)
+		sb.WriteRune(code)
+	} else if previous.hasStyle() {
+		sb.WriteRune(legacyFormattingChar)
+		sb.WriteRune('r')
+	}
+
+	for _, decoration := range []struct {
+		enabled *bool
+		code    rune
+	}{
+		{style.Obfuscated, 'k'},
+		{style.Bold, 'l'},
+		{style.Strikethrough, 'm'},
+		{style.Underlined, 'n'},
+		{style.Italic, 'o'},
+	} {
+		if isSet(decoration.enabled) {
+			sb.WriteRune(legacyFormattingChar)
+			sb.WriteRune(decoration.code)
+		}
+	}
+}
+
+func legacyColorCode(color string) rune {
+	for code, name := range legacyColors {
+		if name == color {
+			return code
+		}
+	}
+	return 0
+}
+
+func (c *TextComponent) hasStyle() bool {
+	return c.Color != "" || c.Bold != nil || c.Italic != nil || c.Underlined != nil ||
+		c.Strikethrough != nil || c.Obfuscated != nil
+}
+
+// simplify collapses a styleless wrapper around exactly one child
+func (c *TextComponent) simplify() *TextComponent {
+	if c.Text == "" && c.Translate == "" && !c.hasStyle() && len(c.Extra) == 1 {
+		return c.Extra[0]
+	}
+	return c
+}
+
+var legacyColors = map[rune]string{
+	'0': "black",
+	'1': "dark_blue",
+	'2': "dark_green",
+	'3': "dark_aqua",
+	'4': "dark_red",
+	'5': "dark_purple",
+	'6': "gold",
+	'7': "gray",
+	'8': "dark_gray",
+	'9': "blue",
+	'a': "green",
+	'b': "aqua",
+	'c': "red",
+	'd': "light_purple",
+	'e': "yellow",
+	'f': "white",
+}
+
+const legacyFormattingChar = '§'
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// ParseLegacyText converts text using legacy § formatting codes into a component tree.
+// The BungeeCord hex color format §x§R§R§G§G§B§B is also supported.
+func ParseLegacyText(s string) *TextComponent {
+	root := &TextComponent{}
+	current := &TextComponent{}
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			segment := *current
+			segment.Text = text.String()
+			root.Extra = append(root.Extra, &segment)
+			text.Reset()
+		}
+	}
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != legacyFormattingChar || i+1 >= len(runes) {
+			text.WriteRune(runes[i])
+			continue
+		}
+
+		code := toLowerRune(runes[i+1])
+		i++
+
+		if code == 'x' && i+12 < len(runes) {
+			if hex, ok := legacyHexColor(runes[i+1 : i+13]); ok {
+				flush()
+				current = &TextComponent{Color: hex}
+				i += 12
+				continue
+			}
+		}
+
+		flush()
+		if color, ok := legacyColors[code]; ok {
+			// Colors reset any formatting, same as the vanilla client
+			current = &TextComponent{Color: color}
+			continue
+		}
+
+		switch code {
+		case 'k':
+			current.Obfuscated = boolPtr(true)
+		case 'l':
+			current.Bold = boolPtr(true)
+		case 'm':
+			current.Strikethrough = boolPtr(true)
+		case 'n':
+			current.Underlined = boolPtr(true)
+		case 'o':
+			current.Italic = boolPtr(true)
+		case 'r':
+			current = &TextComponent{}
+		default:
+			text.WriteRune(legacyFormattingChar)
+			text.WriteRune(runes[i])
+		}
+	}
+	flush()
+
+	if len(root.Extra) == 0 {
+		return root
+	}
+	return root.simplify()
+}
+
+func toLowerRune(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r + ('a' - 'A')
+	}
+	return r
+}
+
+// legacyHexColor decodes the twelve runes following §x, which are six § prefixed hex digits
+func legacyHexColor(runes []rune) (string, bool) {
+	var sb strings.Builder
+	sb.WriteRune('#')
+	for i := 0; i < 12; i += 2 {
+		if runes[i] != legacyFormattingChar || !isHexDigit(runes[i+1]) {
+			return "", false
+		}
+		sb.WriteRune(toLowerRune(runes[i+1]))
+	}
+	return sb.String(), true
+}
+
+func isHexDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= 'a' && r <= 'f') || (r >= 'A' && r <= 'F')
+}
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+var namedColors = map[string]bool{}
+
+func init() {
+	for _, color := range legacyColors {
+		namedColors[color] = true
+	}
+}
+
+// miniMessageDecorations maps MiniMessage decoration tags and aliases to the component field setter
+var miniMessageDecorations = map[string]func(c *TextComponent, v *bool){
+	"bold":          func(c *TextComponent, v *bool) { c.Bold = v },
+	"b":             func(c *TextComponent, v *bool) { c.Bold = v },
+	"italic":        func(c *TextComponent, v *bool) { c.Italic = v },
+	"i":             func(c *TextComponent, v *bool) { c.Italic = v },
+	"em":            func(c *TextComponent, v *bool) { c.Italic = v },
+	"underlined":    func(c *TextComponent, v *bool) { c.Underlined = v },
+	"u":             func(c *TextComponent, v *bool) { c.Underlined = v },
+	"strikethrough": func(c *TextComponent, v *bool) { c.Strikethrough = v },
+	"st":            func(c *TextComponent, v *bool) { c.Strikethrough = v },
+	"obfuscated":    func(c *TextComponent, v *bool) { c.Obfuscated = v },
+	"obf":           func(c *TextComponent, v *bool) { c.Obfuscated = v },
+}
+
+type miniMessageFrame struct {
+	tag       string
+	component *TextComponent
+}
+
+// ParseMiniMessage converts text using MiniMessage style tags, such as <red>, <#ff8800>, <bold>,
+// <reset>, <newline> and <lang:key:arg>, into a component tree. Tags that are not recognized
+// are kept as literal text and a tag can be escaped with a backslash.
+// See https://docs.advntr.dev/minimessage/format.html
+func ParseMiniMessage(s string) *TextComponent {
+	root := &TextComponent{}
+	stack := []miniMessageFrame{{component: root}}
+	var text strings.Builder
+
+	top := func() *TextComponent {
+		return stack[len(stack)-1].component
+	}
+	flush := func() {
+		if text.Len() > 0 {
+			parent := top()
+			parent.Extra = append(parent.Extra, NewTextComponent(text.String()))
+			text.Reset()
+		}
+	}
+	push := func(tag string, component *TextComponent) {
+		parent := top()
+		parent.Extra = append(parent.Extra, component)
+		stack = append(stack, miniMessageFrame{tag: tag, component: component})
+	}
+
+	applyTag := func(tag string) bool {
+		if closing, ok := strings.CutPrefix(tag, "/"); ok {
+			name, _, _ := strings.Cut(closing, ":")
+			name = normalizeMiniMessageTag(name)
+			if name == "colour" || name == "c" {
+				name = "color"
+			}
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tag == name {
+					flush()
+					stack = stack[:i]
+					return true
+				}
+			}
+			return false
+		}
+
+		name, args := splitMiniMessageTag(tag)
+		negated := false
+		if rest, ok := strings.CutPrefix(name, "!"); ok {
+			negated = true
+			name = rest
+		}
+		name = normalizeMiniMessageTag(name)
+
+		if setter, ok := miniMessageDecorations[name]; ok {
+			flush()
+			component := &TextComponent{}
+			setter(component, boolPtr(!negated))
+			push(name, component)
+			return true
+		}
+		if negated {
+			return false
+		}
+
+		switch name {
+		case "reset":
+			flush()
+			stack = stack[:1]
+			return true
+		case "newline", "br":
+			text.WriteRune('\n')
+			return true
+		case "lang", "tr", "translate":
+			if len(args) == 0 {
+				return false
+			}
+			flush()
+			component := &TextComponent{Translate: args[0]}
+			for _, arg := range args[1:] {
+				component.With = append(component.With, ParseMiniMessage(arg))
+			}
+			parent := top()
+			parent.Extra = append(parent.Extra, component)
+			return true
+		case "color", "colour", "c":
+			if len(args) != 1 {
+				return false
+			}
+			// Closed by </color>, </colour> or </c> rather than the name of the color
+			color, ok := miniMessageColor(args[0])
+			if !ok {
+				return false
+			}
+			flush()
+			push("color", &TextComponent{Color: color})
+			return true
+		}
+
+		if color, ok := miniMessageColor(name); ok {
+			flush()
+			push(name, &TextComponent{Color: color})
+			return true
+		}
+		return false
+	}
+
+	for i := 0; i < len(s); {
+		ch := s[i]
+		if ch == '\\' && i+1 < len(s) && (s[i+1] == '<' || s[i+1] == '\\') {
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+		if ch == '<' {
+			if end := strings.IndexByte(s[i:], '>'); end > 1 {
+				if applyTag(s[i+1 : i+end]) {
+					i += end + 1
+					continue
+				}
+			}
+		}
+		text.WriteByte(ch)
+		i++
+	}
+	flush()
+
+	if len(root.Extra) == 0 {
+		return root
+	}
+	return root.simplify()
+}
+
+func normalizeMiniMessageTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// splitMiniMessageTag splits a tag into its name and colon separated arguments, honoring quotes
+func splitMiniMessageTag(tag string) (string, []string) {
+	var parts []string
+	var current strings.Builder
+	var quote byte
+	for i := 0; i < len(tag); i++ {
+		ch := tag[i]
+		switch {
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '\'' || ch == '"'):
+			quote = ch
+		case quote == 0 && ch == ':':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	parts = append(parts, current.String())
+	return parts[0], parts[1:]
+}
+
+func miniMessageColor(name string) (string, bool) {
+	name = strings.ToLower(name)
+	switch name {
+	case "grey":
+		name = "gray"
+	case "dark_grey":
+		name = "dark_gray"
+	}
+	if namedColors[name] {
+		return name, true
+	}
+	if hexColorPattern.MatchString(name) {
+		return name, true
+	}
+	return "", false
+}
+
+// ParseText converts configured text into a component. JSON components are used as-is, text
+// containing § codes is parsed as legacy formatting and anything else is parsed as MiniMessage.
+func ParseText(s string) *TextComponent {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		var component TextComponent
+		if err := json.Unmarshal([]byte(s), &component); err == nil {
+			return &component
+		}
+	}
+	if strings.ContainsRune(s, legacyFormattingChar) {
+		return ParseLegacyText(s)
+	}
+	return ParseMiniMessage(s)
+}
@@ -0,0 +1,51 @@
+package mcproto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMiniMessageColorTag(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "color",
+			input: "<color:red>a</color> b",
+			want:  `{"text":"","extra":[{"text":"","color":"red","extra":[{"text":"a"}]},{"text":" b"}]}`,
+		},
+		{
+			name:  "colour",
+			input: "<colour:red>a</colour> b",
+			want:  `{"text":"","extra":[{"text":"","color":"red","extra":[{"text":"a"}]},{"text":" b"}]}`,
+		},
+		{
+			name:  "short",
+			input: "<c:#ff0000>a</c> b",
+			want:  `{"text":"","extra":[{"text":"","color":"#ff0000","extra":[{"text":"a"}]},{"text":" b"}]}`,
+		},
+		{
+			name:  "alias closed by another alias",
+			input: "<c:red>a</color> b",
+			want:  `{"text":"","extra":[{"text":"","color":"red","extra":[{"text":"a"}]},{"text":" b"}]}`,
+		},
+		{
+			name:  "color name",
+			input: "<red>a</red> b",
+			want:  `{"text":"","extra":[{"text":"","color":"red","extra":[{"text":"a"}]},{"text":" b"}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := json.Marshal(ParseMiniMessage(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package mcproto
+
+import (
+	"bytes"
+	"crypto/md5"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// KnownPack identifies a data pack that both sides may have, which lets the server send registry
+// entries without their data
+type KnownPack struct {
+	Namespace string
+	Id        string
+	Version   string
+}
+
+// OfflinePlayerUUID returns the UUID a server in offline mode assigns to the player name,
+// the same as Java's UUID.nameUUIDFromBytes of "OfflinePlayer:" + name
+func OfflinePlayerUUID(name string) uuid.UUID {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name))
+	sum[6] = (sum[6] & 0x0f) | 0x30
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return uuid.UUID(sum)
+}
+
+// WriteLoginSuccess writes the login success packet without any profile properties, which
+// makes the client switch to the configuration state once it acknowledges it
+func WriteLoginSuccess(writer io.Writer, protocolVersion ProtocolVersion, playerUuid uuid.UUID, name string) error {
+	buf := new(bytes.Buffer)
+	buf.Write(playerUuid[:])
+	if err := WriteString(buf, name); err != nil {
+		return err
+	}
+	// No properties
+	if err := WriteVarInt(buf, 0); err != nil {
+		return err
+	}
+	if protocolVersion < ProtocolVersion1_21_2 {
+		// Strict error handling, only present in 1.20.5 and 1.21
+		buf.WriteByte(0)
+	}
+
+	return WritePacket(writer, PacketIdLoginSuccess, buf.Bytes())
+}
+
+// WriteFeatureFlags writes the feature flags packet enabling the given features
+func WriteFeatureFlags(writer io.Writer, flags []string) error {
+	buf := new(bytes.Buffer)
+	if err := WriteVarInt(buf, len(flags)); err != nil {
+		return err
+	}
+	for _, flag := range flags {
+		if err := WriteString(buf, flag); err != nil {
+			return err
+		}
+	}
+
+	return WritePacket(writer, PacketIdFeatureFlags, buf.Bytes())
+}
+
+// WriteSelectKnownPacks writes the packet asking the client which of the given packs it has
+func WriteSelectKnownPacks(writer io.Writer, packs []KnownPack) error {
+	buf := new(bytes.Buffer)
+	if err := WriteVarInt(buf, len(packs)); err != nil {
+		return err
+	}
+	for _, pack := range packs {
+		for _, s := range []string{pack.Namespace, pack.Id, pack.Version} {
+			if err := WriteString(buf, s); err != nil {
+				return err
+			}
+		}
+	}
+
+	return WritePacket(writer, PacketIdSelectKnownPacks, buf.Bytes())
+}
+
+// DecodeKnownPacks takes the Packet.Data bytes of the client's known packs response and
+// returns the packs it has
+func DecodeKnownPacks(data interface{}) ([]KnownPack, error) {
+	dataBytes, ok := data.([]byte)
+	if !ok {
+		return nil, errors.New(invalidPacketDataBytesMsg)
+	}
+
+	buffer := bytes.NewBuffer(dataBytes)
+	count, err := ReadVarInt(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read known pack count")
+	}
+
+	var packs []KnownPack
+	for i := 0; i < count; i++ {
+		var pack KnownPack
+		for _, field := range []*string{&pack.Namespace, &pack.Id, &pack.Version} {
+			if *field, err = ReadString(buffer); err != nil {
+				return nil, errors.Wrap(err, "failed to read known pack")
+			}
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// WriteRegistryData writes the entries of a registry without their data, which the client
+// then takes from a known pack
+func WriteRegistryData(writer io.Writer, registry string, entries []string) error {
+	buf := new(bytes.Buffer)
+	if err := WriteString(buf, registry); err != nil {
+		return err
+	}
+	if err := WriteVarInt(buf, len(entries)); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := WriteString(buf, entry); err != nil {
+			return err
+		}
+		// No data included
+		buf.WriteByte(0)
+	}
+
+	return WritePacket(writer, PacketIdRegistryData, buf.Bytes())
+}
+
+// WriteEmptyTags writes an update tags packet that declares no tags
+func WriteEmptyTags(writer io.Writer) error {
+	buf := new(bytes.Buffer)
+	if err := WriteVarInt(buf, 0); err != nil {
+		return err
+	}
+	return WritePacket(writer, PacketIdUpdateTags, buf.Bytes())
+}
+
+// WriteFinishConfiguration writes the packet that makes the client switch to the play state
+// once it acknowledges it
+func WriteFinishConfiguration(writer io.Writer) error {
+	return WritePacket(writer, PacketIdFinishConfiguration, nil)
+}
+
+// WriteConfigurationDisconnect writes a disconnect packet during the configuration state
+func WriteConfigurationDisconnect(writer io.Writer, reason *TextComponent) error {
+	buf := new(bytes.Buffer)
+	if err := WriteTextComponentNBT(buf, reason); err != nil {
+		return err
+	}
+	return WritePacket(writer, PacketIdConfigurationDisconnect, buf.Bytes())
+}
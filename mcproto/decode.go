@@ -32,8 +32,8 @@ func DecodeHandshake(data interface{}) (*Handshake, error) {
 		return nil, err
 	}
 
-	// Forge Mod Loader adds some data after the server address. Truncate it.
-	handshake.ServerAddress, _, _ = strings.Cut(handshake.ServerAddress, string(rune(0)))
+	// Forge Mod Loader and BungeeCord add some data after the server address. Keep it apart.
+	handshake.ServerAddress, handshake.AddressData, _ = strings.Cut(handshake.ServerAddress, string(rune(0)))
 
 	handshake.ServerPort, err = ReadUnsignedShort(buffer)
 	if err != nil {
@@ -131,3 +131,18 @@ func DecodeLoginStart(protocolVersion ProtocolVersion, data interface{}) (*Login
 
 	return loginStart, nil
 }
+
+// DecodeStatusResponse takes the Packet.Data bytes of a status response and returns the
+// JSON status it contains
+func DecodeStatusResponse(data interface{}) ([]byte, error) {
+	dataBytes, ok := data.([]byte)
+	if !ok {
+		return nil, errors.New(invalidPacketDataBytesMsg)
+	}
+
+	status, err := ReadString(bytes.NewBuffer(dataBytes))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read status json")
+	}
+	return []byte(status), nil
+}
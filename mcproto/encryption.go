@@ -0,0 +1,152 @@
+package mcproto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PacketIdEncryptionRequest  = 0x01 // during StateLogin, sent by the server
+	PacketIdEncryptionResponse = 0x01 // during StateLogin, sent by the client
+)
+
+// EncryptionResponse holds the shared secret and verify token the client encrypted with the
+// server's public key
+type EncryptionResponse struct {
+	SharedSecret []byte
+	// VerifyToken is nil if a 1.19 to 1.19.2 client signed the nonce with its chat key instead,
+	// which is not supported
+	VerifyToken []byte
+}
+
+// WriteEncryptionRequest writes the packet that starts encryption during login. The public key
+// is the DER encoded SubjectPublicKeyInfo of the server's RSA key.
+func WriteEncryptionRequest(writer io.Writer, protocolVersion ProtocolVersion, serverId string, publicKey, verifyToken []byte) error {
+	buf := new(bytes.Buffer)
+	if err := WriteString(buf, serverId); err != nil {
+		return err
+	}
+	_ = WriteVarInt(buf, len(publicKey))
+	buf.Write(publicKey)
+	_ = WriteVarInt(buf, len(verifyToken))
+	buf.Write(verifyToken)
+	if protocolVersion >= ProtocolVersion1_20_5 {
+		// The client should authenticate with the session server
+		buf.WriteByte(1)
+	}
+
+	return WritePacket(writer, PacketIdEncryptionRequest, buf.Bytes())
+}
+
+// DecodeEncryptionResponse takes the Packet.Data bytes of an encryption response
+func DecodeEncryptionResponse(protocolVersion ProtocolVersion, data interface{}) (*EncryptionResponse, error) {
+	dataBytes, ok := data.([]byte)
+	if !ok {
+		return nil, errors.New(invalidPacketDataBytesMsg)
+	}
+
+	buffer := bytes.NewBuffer(dataBytes)
+	response := &EncryptionResponse{}
+
+	var err error
+	response.SharedSecret, err = readPrefixedBytes(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read shared secret")
+	}
+
+	if protocolVersion >= ProtocolVersion1_19 && protocolVersion <= ProtocolVersion1_19_2 {
+		hasVerifyToken, err := ReadBoolean(buffer)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read has verify token flag")
+		}
+		if !hasVerifyToken {
+			return response, nil
+		}
+	}
+
+	response.VerifyToken, err = readPrefixedBytes(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read verify token")
+	}
+	return response, nil
+}
+
+func readPrefixedBytes(reader io.Reader) ([]byte, error) {
+	length, err := ReadVarInt(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 || length > MaxFrameLength {
+		return nil, errors.Errorf("invalid length %d", length)
+	}
+	return ReadByteArray(reader, length)
+}
+
+// AuthDigest returns the server hash a client sends to the session server when joining, which
+// is the SHA-1 of the server id, shared secret and public key formatted as a signed hex number
+func AuthDigest(serverId string, sharedSecret, publicKey []byte) string {
+	h := sha1.New()
+	h.Write([]byte(serverId))
+	h.Write(sharedSecret)
+	h.Write(publicKey)
+	sum := h.Sum(nil)
+
+	negative := sum[0]&0x80 != 0
+	if negative {
+		// Two's complement
+		value := new(big.Int).SetBytes(sum)
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), uint(len(sum)*8)))
+		return "-" + strings.TrimLeft(hex.EncodeToString(new(big.Int).Neg(value).Bytes()), "0")
+	}
+	return strings.TrimLeft(hex.EncodeToString(sum), "0")
+}
+
+// cfb8 implements the 8-bit cipher feedback mode used to encrypt the connection, where the shared
+// secret is both the AES key and the initialization vector
+type cfb8 struct {
+	block   cipher.Block
+	iv      []byte
+	out     []byte
+	decrypt bool
+}
+
+// NewCFB8Encrypter returns a stream that encrypts with the block cipher in CFB8 mode
+func NewCFB8Encrypter(block cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(block, iv, false)
+}
+
+// NewCFB8Decrypter returns a stream that decrypts with the block cipher in CFB8 mode
+func NewCFB8Decrypter(block cipher.Block, iv []byte) cipher.Stream {
+	return newCFB8(block, iv, true)
+}
+
+func newCFB8(block cipher.Block, iv []byte, decrypt bool) *cfb8 {
+	return &cfb8{
+		block:   block,
+		iv:      bytes.Clone(iv),
+		out:     make([]byte, block.BlockSize()),
+		decrypt: decrypt,
+	}
+}
+
+func (c *cfb8) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		c.block.Encrypt(c.out, c.iv)
+		in := src[i]
+		dst[i] = in ^ c.out[0]
+
+		copy(c.iv, c.iv[1:])
+		if c.decrypt {
+			c.iv[len(c.iv)-1] = in
+		} else {
+			c.iv[len(c.iv)-1] = dst[i]
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package mcproto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	PacketIdLoginPluginRequest  = 0x04 // during StateLogin, sent by the server
+	PacketIdLoginPluginResponse = 0x02 // during StateLogin, sent by the client
+)
+
+const (
+	// VelocityForwardingChannel is the login plugin channel of Velocity modern forwarding
+	VelocityForwardingChannel = "velocity:player_info"
+	// VelocityForwardingVersion is the modern forwarding version requested, which carries
+	// the player's address, UUID, name and profile properties
+	VelocityForwardingVersion = 1
+)
+
+// ForwardedPlayer holds the player details a proxy forwards to the server behind it
+type ForwardedPlayer struct {
+	Address netip.Addr
+	Uuid    uuid.UUID
+	// Name is only forwarded by Velocity, BungeeCord leaves it to the login start packet
+	Name string
+}
+
+// DecodeBungeeCordForwarding decodes the data BungeeCord IP forwarding appends to the server
+// address of the handshake, which is the client address, the player UUID and the profile
+// properties, separated by NUL characters
+func DecodeBungeeCordForwarding(addressData string) (*ForwardedPlayer, error) {
+	parts := strings.Split(addressData, "\x00")
+	if len(parts) < 2 {
+		return nil, errors.New("missing BungeeCord forwarding data")
+	}
+
+	address, err := netip.ParseAddr(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid forwarded address")
+	}
+	playerUuid, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid forwarded uuid")
+	}
+	return &ForwardedPlayer{Address: address.Unmap(), Uuid: playerUuid}, nil
+}
+
+// LoginPluginResponse is the answer of the client to a login plugin request
+type LoginPluginResponse struct {
+	MessageId  int
+	Successful bool
+	Data       []byte
+}
+
+// WriteLoginPluginRequest writes a login plugin request on the given channel
+func WriteLoginPluginRequest(writer io.Writer, messageId int, channel string, data []byte) error {
+	buf := new(bytes.Buffer)
+	if err := WriteVarInt(buf, messageId); err != nil {
+		return err
+	}
+	if err := WriteString(buf, channel); err != nil {
+		return err
+	}
+	buf.Write(data)
+
+	return WritePacket(writer, PacketIdLoginPluginRequest, buf.Bytes())
+}
+
+// DecodeLoginPluginResponse takes the Packet.Data bytes of a login plugin response
+func DecodeLoginPluginResponse(data interface{}) (*LoginPluginResponse, error) {
+	dataBytes, ok := data.([]byte)
+	if !ok {
+		return nil, errors.New(invalidPacketDataBytesMsg)
+	}
+
+	buffer := bytes.NewBuffer(dataBytes)
+	response := &LoginPluginResponse{}
+
+	var err error
+	response.MessageId, err = ReadVarInt(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read message id")
+	}
+	response.Successful, err = ReadBoolean(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read successful flag")
+	}
+	response.Data = buffer.Bytes()
+	return response, nil
+}
+
+// DecodeVelocityForwarding verifies the HMAC-SHA256 signature that starts the modern forwarding
+// data with the secret shared with Velocity and decodes the forwarded player
+func DecodeVelocityForwarding(data []byte, secret []byte) (*ForwardedPlayer, error) {
+	if len(data) < sha256.Size {
+		return nil, errors.New("truncated Velocity forwarding data")
+	}
+
+	signature, signed := data[:sha256.Size], data[sha256.Size:]
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signed)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid Velocity forwarding signature")
+	}
+
+	buffer := bytes.NewBuffer(signed)
+	version, err := ReadVarInt(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read forwarding version")
+	}
+	if version < VelocityForwardingVersion {
+		return nil, errors.Errorf("unsupported forwarding version %d", version)
+	}
+
+	addressText, err := ReadString(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read forwarded address")
+	}
+	address, err := netip.ParseAddr(addressText)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid forwarded address")
+	}
+	playerUuid, err := ReadUuid(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read forwarded uuid")
+	}
+	name, err := ReadString(buffer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read forwarded name")
+	}
+
+	return &ForwardedPlayer{Address: address.Unmap(), Uuid: playerUuid, Name: name}, nil
+}
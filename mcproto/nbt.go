@@ -0,0 +1,154 @@
+package mcproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	nbtTagEnd      = 0
+	nbtTagByte     = 1
+	nbtTagString   = 8
+	nbtTagList     = 9
+	nbtTagCompound = 10
+)
+
+// WriteTextComponentNBT writes the text component in the network NBT form used for chat
+// components in the configuration and play states since 1.20.3, which is an unnamed root compound.
+func WriteTextComponentNBT(writer io.Writer, component *TextComponent) error {
+	data, err := json.Marshal(component)
+	if err != nil {
+		return err
+	}
+
+	var value map[string]any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(nbtTagCompound)
+	if err := writeNBTCompound(buf, value); err != nil {
+		return err
+	}
+	_, err = writer.Write(buf.Bytes())
+	return err
+}
+
+func writeNBTCompound(buf *bytes.Buffer, value map[string]any) error {
+	for name, entry := range value {
+		tag, err := nbtTagOf(entry)
+		if err != nil {
+			return errors.Wrapf(err, "field %s", name)
+		}
+		buf.WriteByte(tag)
+		if err := writeNBTString(buf, name); err != nil {
+			return err
+		}
+		if err := writeNBTPayload(buf, entry); err != nil {
+			return errors.Wrapf(err, "field %s", name)
+		}
+	}
+	buf.WriteByte(nbtTagEnd)
+	return nil
+}
+
+func nbtTagOf(value any) (byte, error) {
+	switch value.(type) {
+	case string:
+		return nbtTagString, nil
+	case bool:
+		return nbtTagByte, nil
+	case []any:
+		return nbtTagList, nil
+	case map[string]any:
+		return nbtTagCompound, nil
+	default:
+		return 0, errors.Errorf("unsupported NBT value %T", value)
+	}
+}
+
+func writeNBTPayload(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case string:
+		return writeNBTString(buf, v)
+	case bool:
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		return nil
+	case []any:
+		return writeNBTList(buf, v)
+	case map[string]any:
+		return writeNBTCompound(buf, v)
+	default:
+		return errors.Errorf("unsupported NBT value %T", value)
+	}
+}
+
+// writeNBTList writes a list whose items are all of one tag type. Lists of text components
+// can mix plain strings and components, so strings are wrapped as components if needed.
+func writeNBTList(buf *bytes.Buffer, items []any) error {
+	if len(items) == 0 {
+		buf.WriteByte(nbtTagEnd)
+		return binary.Write(buf, binary.BigEndian, int32(0))
+	}
+
+	tag, err := nbtTagOf(items[0])
+	if err != nil {
+		return err
+	}
+	for _, item := range items[1:] {
+		if itemTag, err := nbtTagOf(item); err != nil {
+			return err
+		} else if itemTag != tag {
+			tag = nbtTagCompound
+		}
+	}
+
+	buf.WriteByte(tag)
+	if err := binary.Write(buf, binary.BigEndian, int32(len(items))); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if text, isString := item.(string); isString && tag == nbtTagCompound {
+			item = map[string]any{"text": text}
+		}
+		if err := writeNBTPayload(buf, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeNBTString writes the string in the modified UTF-8 encoding of Java's DataOutput.writeUTF,
+// where characters outside the Basic Multilingual Plane are written as two encoded surrogates.
+func writeNBTString(buf *bytes.Buffer, s string) error {
+	encoded := make([]byte, 0, len(s))
+	for _, unit := range utf16.Encode([]rune(s)) {
+		switch {
+		case unit != 0 && unit < 0x80:
+			encoded = append(encoded, byte(unit))
+		case unit < 0x800:
+			encoded = append(encoded, byte(0xC0|unit>>6), byte(0x80|unit&0x3F))
+		default:
+			encoded = append(encoded, byte(0xE0|unit>>12), byte(0x80|(unit>>6)&0x3F), byte(0x80|unit&0x3F))
+		}
+	}
+
+	if len(encoded) > 0xFFFF {
+		return errors.New("NBT string too long")
+	}
+	if err := binary.Write(buf, binary.BigEndian, uint16(len(encoded))); err != nil {
+		return err
+	}
+	buf.Write(encoded)
+	return nil
+}
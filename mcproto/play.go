@@ -0,0 +1,301 @@
+package mcproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// playPacketIds holds the ids of the play state packets sent by the server, which are
+// renumbered whenever a version adds or removes packets
+type playPacketIds struct {
+	bossBar        int
+	disconnect     int
+	gameEvent      int
+	keepAlive      int
+	login          int
+	playerPosition int
+	actionBar      int
+	transfer       int
+}
+
+func playPacketIdsFor(protocolVersion ProtocolVersion) (*playPacketIds, error) {
+	switch {
+	case protocolVersion >= ProtocolVersion1_21_5 && protocolVersion <= ProtocolVersion1_21_7:
+		return &playPacketIds{
+			bossBar: 0x09, disconnect: 0x1C, gameEvent: 0x22, keepAlive: 0x26,
+			login: 0x2B, playerPosition: 0x41, actionBar: 0x50, transfer: 0x7A,
+		}, nil
+	case protocolVersion >= ProtocolVersion1_21_2 && protocolVersion <= ProtocolVersion1_21_4:
+		return &playPacketIds{
+			bossBar: 0x0A, disconnect: 0x1D, gameEvent: 0x23, keepAlive: 0x27,
+			login: 0x2C, playerPosition: 0x42, actionBar: 0x51, transfer: 0x7A,
+		}, nil
+	case protocolVersion >= ProtocolVersion1_20_5 && protocolVersion <= ProtocolVersion1_21:
+		return &playPacketIds{
+			bossBar: 0x0A, disconnect: 0x1D, gameEvent: 0x22, keepAlive: 0x26,
+			login: 0x2B, playerPosition: 0x40, actionBar: 0x4C, transfer: 0x73,
+		}, nil
+	default:
+		return nil, errors.Errorf("play state is not supported for protocol version %d", protocolVersion)
+	}
+}
+
+// PlayStateSupported reports whether the play state packets can be written for the protocol version.
+// Known packs, which make it possible to join a world without sending registry data, and the
+// transfer packet are both available since 1.20.5. Versions newer than the known ones aren't
+// supported, since their packets may be numbered differently.
+func PlayStateSupported(protocolVersion ProtocolVersion) bool {
+	_, err := playPacketIdsFor(protocolVersion)
+	return err == nil
+}
+
+const (
+	GameModeSpectator = 3
+
+	// GameEventStartWaitingForChunks lets the client leave the loading screen once the chunk the
+	// player is in has loaded, which happens right away for a spectator
+	GameEventStartWaitingForChunks = 13
+)
+
+// BossBarColor is the color of a boss bar
+type BossBarColor int
+
+const (
+	BossBarPink BossBarColor = iota
+	BossBarBlue
+	BossBarRed
+	BossBarGreen
+	BossBarYellow
+	BossBarPurple
+	BossBarWhite
+)
+
+const (
+	bossBarActionAdd          = 0
+	bossBarActionUpdateHealth = 2
+	bossBarActionUpdateTitle  = 3
+)
+
+// PlayLogin declares the world the player joins
+type PlayLogin struct {
+	EntityId            int32
+	MaxPlayers          int
+	ViewDistance        int
+	DimensionName       string
+	GameMode            byte
+	ReducedDebugInfo    bool
+	EnableRespawnScreen bool
+}
+
+// WritePlayLogin writes the login packet that starts the play state. The dimension type is
+// the first entry of the dimension type registry sent during configuration.
+func WritePlayLogin(writer io.Writer, protocolVersion ProtocolVersion, login *PlayLogin) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	writeInt(buf, login.EntityId)
+	writeBool(buf, false) // hardcore
+	_ = WriteVarInt(buf, 1)
+	_ = WriteString(buf, login.DimensionName)
+	_ = WriteVarInt(buf, login.MaxPlayers)
+	_ = WriteVarInt(buf, login.ViewDistance)
+	_ = WriteVarInt(buf, login.ViewDistance) // simulation distance
+	writeBool(buf, login.ReducedDebugInfo)
+	writeBool(buf, login.EnableRespawnScreen)
+	writeBool(buf, false) // limited crafting
+	_ = WriteVarInt(buf, 0)
+	_ = WriteString(buf, login.DimensionName)
+	writeLong(buf, 0) // hashed seed
+	buf.WriteByte(login.GameMode)
+	buf.WriteByte(0xFF)   // no previous game mode
+	writeBool(buf, false) // debug world
+	writeBool(buf, true)  // flat world
+	writeBool(buf, false) // no death location
+	_ = WriteVarInt(buf, 0)
+	if protocolVersion >= ProtocolVersion1_21_2 {
+		_ = WriteVarInt(buf, 63) // sea level
+	}
+	writeBool(buf, false) // enforces secure chat
+
+	return WritePacket(writer, ids.login, buf.Bytes())
+}
+
+// WritePlayerPosition writes the packet that places the player at the given coordinates
+func WritePlayerPosition(writer io.Writer, protocolVersion ProtocolVersion, x, y, z float64, teleportId int) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if protocolVersion >= ProtocolVersion1_21_2 {
+		_ = WriteVarInt(buf, teleportId)
+		writeDoubles(buf, x, y, z, 0, 0, 0)
+		writeFloats(buf, 0, 0)
+		writeInt(buf, 0) // absolute position
+	} else {
+		writeDoubles(buf, x, y, z)
+		writeFloats(buf, 0, 0)
+		buf.WriteByte(0) // absolute position
+		_ = WriteVarInt(buf, teleportId)
+	}
+
+	return WritePacket(writer, ids.playerPosition, buf.Bytes())
+}
+
+// WriteGameEvent writes a game event packet
+func WriteGameEvent(writer io.Writer, protocolVersion ProtocolVersion, event byte, value float32) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(event)
+	writeFloats(buf, value)
+	return WritePacket(writer, ids.gameEvent, buf.Bytes())
+}
+
+// WritePlayKeepAlive writes a keep alive packet during the play state
+func WritePlayKeepAlive(writer io.Writer, protocolVersion ProtocolVersion, id int64) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	writeLong(buf, id)
+	return WritePacket(writer, ids.keepAlive, buf.Bytes())
+}
+
+// WriteBossBarAdd writes the packet that shows a new boss bar without any divisions
+func WriteBossBarAdd(writer io.Writer, protocolVersion ProtocolVersion, id uuid.UUID, title *TextComponent,
+	health float32, color BossBarColor) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(id[:])
+	_ = WriteVarInt(buf, bossBarActionAdd)
+	if err := WriteTextComponentNBT(buf, title); err != nil {
+		return err
+	}
+	writeFloats(buf, health)
+	_ = WriteVarInt(buf, int(color))
+	_ = WriteVarInt(buf, 0) // no divisions
+	buf.WriteByte(0)        // no flags
+	return WritePacket(writer, ids.bossBar, buf.Bytes())
+}
+
+// WriteBossBarHealth writes the packet that changes how full a boss bar is, from 0 to 1
+func WriteBossBarHealth(writer io.Writer, protocolVersion ProtocolVersion, id uuid.UUID, health float32) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(id[:])
+	_ = WriteVarInt(buf, bossBarActionUpdateHealth)
+	writeFloats(buf, health)
+	return WritePacket(writer, ids.bossBar, buf.Bytes())
+}
+
+// WriteBossBarTitle writes the packet that changes the title of a boss bar
+func WriteBossBarTitle(writer io.Writer, protocolVersion ProtocolVersion, id uuid.UUID, title *TextComponent) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(id[:])
+	_ = WriteVarInt(buf, bossBarActionUpdateTitle)
+	if err := WriteTextComponentNBT(buf, title); err != nil {
+		return err
+	}
+	return WritePacket(writer, ids.bossBar, buf.Bytes())
+}
+
+// WriteActionBar writes the text shown above the hotbar
+func WriteActionBar(writer io.Writer, protocolVersion ProtocolVersion, text *TextComponent) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteTextComponentNBT(buf, text); err != nil {
+		return err
+	}
+	return WritePacket(writer, ids.actionBar, buf.Bytes())
+}
+
+// WriteTransfer writes the packet that makes the client connect to another server. The server
+// it is transferred to needs to accept transfers.
+func WriteTransfer(writer io.Writer, protocolVersion ProtocolVersion, host string, port int) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteString(buf, host); err != nil {
+		return err
+	}
+	_ = WriteVarInt(buf, port)
+	return WritePacket(writer, ids.transfer, buf.Bytes())
+}
+
+// WritePlayDisconnect writes a disconnect packet during the play state
+func WritePlayDisconnect(writer io.Writer, protocolVersion ProtocolVersion, reason *TextComponent) error {
+	ids, err := playPacketIdsFor(protocolVersion)
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := WriteTextComponentNBT(buf, reason); err != nil {
+		return err
+	}
+	return WritePacket(writer, ids.disconnect, buf.Bytes())
+}
+
+// The helpers below write to a bytes.Buffer, which never fails
+
+func writeBool(buf *bytes.Buffer, value bool) {
+	if value {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+}
+
+func writeInt(buf *bytes.Buffer, value int32) {
+	_ = binary.Write(buf, binary.BigEndian, value)
+}
+
+func writeLong(buf *bytes.Buffer, value int64) {
+	_ = binary.Write(buf, binary.BigEndian, value)
+}
+
+func writeFloats(buf *bytes.Buffer, values ...float32) {
+	for _, value := range values {
+		_ = binary.Write(buf, binary.BigEndian, value)
+	}
+}
+
+func writeDoubles(buf *bytes.Buffer, values ...float64) {
+	for _, value := range values {
+		_ = binary.Write(buf, binary.BigEndian, value)
+	}
+}
@@ -67,6 +67,11 @@ func ReadPacket(reader *bufio.Reader, addr net.Addr, state State) (*Packet, erro
 	return packet, nil
 }
 
+// ReadLegacyServerListPing reads any of the pre-1.7 server list ping variants.
+// Like the vanilla server, the variant is determined by how much of the request arrived
+// with the first read since beta and 1.4 clients send nothing beyond the initial bytes.
+// Pre-1.6 clients also accept the beta style response, so a 1.6 request that gets split
+// is still answered correctly.
 func ReadLegacyServerListPing(reader *bufio.Reader, addr net.Addr) (*Packet, error) {
 	logrus.
 		WithField("client", addr).
@@ -80,6 +85,10 @@ func ReadLegacyServerListPing(reader *bufio.Reader, addr net.Addr) (*Packet, err
 		return nil, errors.Errorf("expected legacy server listing ping packet ID, got %x", packetId)
 	}
 
+	if reader.Buffered() == 0 {
+		return newLegacyServerListPingPacket(&LegacyServerListPing{Variant: LegacyPingBeta}), nil
+	}
+
 	payload, err := reader.ReadByte()
 	if err != nil {
 		return nil, err
@@ -88,6 +97,10 @@ func ReadLegacyServerListPing(reader *bufio.Reader, addr net.Addr) (*Packet, err
 		return nil, errors.Errorf("expected payload=1 from legacy server listing ping, got %x", payload)
 	}
 
+	if reader.Buffered() == 0 {
+		return newLegacyServerListPingPacket(&LegacyServerListPing{Variant: LegacyPing1_4}), nil
+	}
+
 	packetIdForPluginMsg, err := reader.ReadByte()
 	if err != nil {
 		return nil, err
@@ -137,15 +150,20 @@ func ReadLegacyServerListPing(reader *bufio.Reader, addr net.Addr) (*Packet, err
 		return nil, err
 	}
 
+	return newLegacyServerListPingPacket(&LegacyServerListPing{
+		Variant:         LegacyPing1_6,
+		ProtocolVersion: int(protocolVersion),
+		ServerAddress:   hostname,
+		ServerPort:      uint16(port),
+	}), nil
+}
+
+func newLegacyServerListPingPacket(ping *LegacyServerListPing) *Packet {
 	return &Packet{
 		PacketID: PacketIdLegacyServerListPing,
 		Length:   0,
-		Data: &LegacyServerListPing{
-			ProtocolVersion: int(protocolVersion),
-			ServerAddress:   hostname,
-			ServerPort:      uint16(port),
-		},
-	}, nil
+		Data:     ping,
+	}
 }
 
 func ReadUTF16BEString(reader io.Reader, symbolLen uint16) (string, error) {
@@ -195,6 +213,10 @@ func ReadFrame(reader io.Reader, addr net.Addr) (*Frame, error) {
 			if err != io.EOF {
 				return nil, err
 			}
+			if n == 0 {
+				// The connection closed part way through the frame, so no progress can ever be made
+				return nil, io.ErrUnexpectedEOF
+			}
 		}
 		total += n
 		logrus.
@@ -0,0 +1,91 @@
+package mcproto
+
+// RegistryEntries lists the entries sent for one synchronized registry
+type RegistryEntries struct {
+	Registry string
+	Entries  []string
+}
+
+// CoreKnownPacks returns the candidates for the vanilla data pack of the given protocol version,
+// one for each game version sharing that protocol, or nil if the protocol predates known packs.
+// A client only accepts the pack of its exact game version.
+func CoreKnownPacks(protocolVersion ProtocolVersion) []KnownPack {
+	var versions []string
+	switch protocolVersion {
+	case ProtocolVersion1_20_5:
+		versions = []string{"1.20.6", "1.20.5"}
+	case ProtocolVersion1_21:
+		versions = []string{"1.21.1", "1.21"}
+	case ProtocolVersion1_21_2:
+		versions = []string{"1.21.3", "1.21.2"}
+	case ProtocolVersion1_21_4:
+		versions = []string{"1.21.4"}
+	case ProtocolVersion1_21_5:
+		versions = []string{"1.21.5"}
+	case ProtocolVersion1_21_6:
+		versions = []string{"1.21.6"}
+	case ProtocolVersion1_21_7:
+		versions = []string{"1.21.8", "1.21.7"}
+	}
+
+	packs := make([]KnownPack, 0, len(versions))
+	for _, version := range versions {
+		packs = append(packs, KnownPack{Namespace: "minecraft", Id: "core", Version: version})
+	}
+	return packs
+}
+
+// MinimalRegistries returns the smallest set of registry entries the client of the given protocol
+// version accepts for joining an empty world. The data of every entry comes from the core known pack.
+// The first dimension type is the one used by WritePlayLogin.
+func MinimalRegistries(protocolVersion ProtocolVersion) []RegistryEntries {
+	// The client looks these up as soon as the world is joined
+	damageTypes := []string{
+		"minecraft:cactus", "minecraft:cramming", "minecraft:dragon_breath", "minecraft:drown",
+		"minecraft:dry_out", "minecraft:fall", "minecraft:fly_into_wall", "minecraft:freeze",
+		"minecraft:generic", "minecraft:generic_kill", "minecraft:hot_floor", "minecraft:in_fire",
+		"minecraft:in_wall", "minecraft:lava", "minecraft:lightning_bolt", "minecraft:magic",
+		"minecraft:on_fire", "minecraft:out_of_world", "minecraft:outside_border", "minecraft:stalagmite",
+		"minecraft:starve", "minecraft:sweet_berry_bush", "minecraft:wither",
+	}
+	if protocolVersion >= ProtocolVersion1_21 {
+		damageTypes = append(damageTypes, "minecraft:campfire")
+	}
+	if protocolVersion >= ProtocolVersion1_21_2 {
+		damageTypes = append(damageTypes, "minecraft:ender_pearl")
+	}
+
+	registries := []RegistryEntries{
+		{"minecraft:dimension_type", []string{"minecraft:overworld"}},
+		{"minecraft:worldgen/biome", []string{"minecraft:plains"}},
+		{"minecraft:damage_type", damageTypes},
+		{"minecraft:chat_type", []string{"minecraft:chat"}},
+		{"minecraft:trim_pattern", []string{"minecraft:coast"}},
+		{"minecraft:trim_material", []string{"minecraft:iron"}},
+		{"minecraft:wolf_variant", []string{"minecraft:pale"}},
+		{"minecraft:banner_pattern", []string{"minecraft:base"}},
+	}
+	if protocolVersion >= ProtocolVersion1_21 {
+		registries = append(registries,
+			RegistryEntries{"minecraft:painting_variant", []string{"minecraft:kebab"}},
+			RegistryEntries{"minecraft:enchantment", []string{"minecraft:sharpness"}},
+			RegistryEntries{"minecraft:jukebox_song", []string{"minecraft:13"}},
+		)
+	}
+	if protocolVersion >= ProtocolVersion1_21_2 {
+		registries = append(registries,
+			RegistryEntries{"minecraft:instrument", []string{"minecraft:ponder_goat_horn"}},
+		)
+	}
+	if protocolVersion >= ProtocolVersion1_21_5 {
+		registries = append(registries,
+			RegistryEntries{"minecraft:cat_variant", []string{"minecraft:tabby"}},
+			RegistryEntries{"minecraft:chicken_variant", []string{"minecraft:temperate"}},
+			RegistryEntries{"minecraft:cow_variant", []string{"minecraft:temperate"}},
+			RegistryEntries{"minecraft:frog_variant", []string{"minecraft:temperate"}},
+			RegistryEntries{"minecraft:pig_variant", []string{"minecraft:temperate"}},
+			RegistryEntries{"minecraft:wolf_sound_variant", []string{"minecraft:classic"}},
+		)
+	}
+	return registries
+}
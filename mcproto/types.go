@@ -21,6 +21,11 @@ const (
 	StateHandshaking State = 0
 	StateStatus      State = 1
 	StateLogin       State = 2
+	// StateTransfer is requested instead of StateLogin by 1.20.5+ clients that arrive through a Transfer packet
+	StateTransfer State = 3
+	// StateConfiguration and StatePlay follow a completed login and are never requested in a handshake
+	StateConfiguration State = 4
+	StatePlay          State = 5
 )
 
 var trimLimit = 64
@@ -97,16 +102,35 @@ const (
 	PacketIdHandshake            = 0x00
 	PacketIdLogin                = 0x00 // during StateLogin
 	PacketIdLegacyServerListPing = 0xFE
+	PacketIdLegacyKick           = 0xFF
 	// Status state packets
-	PacketIdStatusRequest = 0x00 // during StateStatus
-	PacketIdPingRequest   = 0x01 // during StateStatus
+	PacketIdStatusRequest  = 0x00 // during StateStatus
+	PacketIdPingRequest    = 0x01 // during StateStatus
+	PacketIdStatusResponse = 0x00 // during StateStatus, sent by the server
+	PacketIdPongResponse   = 0x01 // during StateStatus, sent by the server
+	// Login state packets
+	PacketIdLoginDisconnect   = 0x00 // during StateLogin, sent by the server
+	PacketIdLoginSuccess      = 0x02 // during StateLogin, sent by the server
+	PacketIdLoginAcknowledged = 0x03 // during StateLogin, 1.20.2+
+	// Configuration state packets, numbered the same in every version since 1.20.5
+	PacketIdConfigurationDisconnect = 0x02 // during StateConfiguration, sent by the server
+	PacketIdFinishConfiguration     = 0x03 // during StateConfiguration, both directions
+	PacketIdRegistryData            = 0x07 // during StateConfiguration, sent by the server
+	PacketIdConfigurationTransfer   = 0x0B // during StateConfiguration, sent by the server
+	PacketIdFeatureFlags            = 0x0C // during StateConfiguration, sent by the server
+	PacketIdUpdateTags              = 0x0D // during StateConfiguration, sent by the server
+	PacketIdSelectKnownPacks        = 0x0E // during StateConfiguration, sent by the server
+	PacketIdKnownPacks              = 0x07 // during StateConfiguration, sent by the client
 )
 
 type Handshake struct {
 	ProtocolVersion ProtocolVersion
 	ServerAddress   string
-	ServerPort      uint16
-	NextState       State
+	// AddressData is what followed a NUL character in the server address, such as Forge
+	// markers or BungeeCord forwarding data
+	AddressData string
+	ServerPort  uint16
+	NextState   State
 }
 
 type LoginStart struct {
@@ -121,7 +145,34 @@ func NewLoginStart() *LoginStart {
 	}
 }
 
+// LegacyPingVariant identifies which generation of pre-1.7 client sent a legacy server list ping
+type LegacyPingVariant int
+
+const (
+	// LegacyPingBeta is sent by Beta 1.8 to 1.3 clients and consists of the packet ID only
+	LegacyPingBeta LegacyPingVariant = iota
+	// LegacyPing1_4 is sent by 1.4 and 1.5 clients and adds a single payload byte
+	LegacyPing1_4
+	// LegacyPing1_6 is sent by 1.6 clients and adds a MC|PingHost plugin message
+	LegacyPing1_6
+)
+
+func (v LegacyPingVariant) String() string {
+	switch v {
+	case LegacyPingBeta:
+		return "beta"
+	case LegacyPing1_4:
+		return "1.4"
+	case LegacyPing1_6:
+		return "1.6"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(v))
+	}
+}
+
 type LegacyServerListPing struct {
+	Variant LegacyPingVariant
+	// ProtocolVersion, ServerAddress and ServerPort are only provided by LegacyPing1_6
 	ProtocolVersion int
 	ServerAddress   string
 	ServerPort      uint16
@@ -135,6 +186,10 @@ const (
 	PacketLengthFieldBytes = 1
 )
 
+// LegacyPingProtocolVersion is reported to pre-1.7 clients, the same as a vanilla 1.7+ server does,
+// so they display the server as running an incompatible version
+const LegacyPingProtocolVersion = 127
+
 // VersionToProtocol maps Minecraft version strings to their corresponding protocol numbers
 func VersionToProtocol(version string) (int, bool) {
 	versionMap := map[string]int{
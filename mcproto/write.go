@@ -4,8 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
-	"fmt"
 	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
 )
 
 // WriteVarInt writes a variable-length integer to the writer
@@ -62,6 +66,30 @@ func WritePacket(writer io.Writer, packetID int, data []byte) error {
 	return err
 }
 
+// WriteHandshake writes a handshake packet as sent by a client
+func WriteHandshake(writer io.Writer, handshake *Handshake) error {
+	buf := new(bytes.Buffer)
+	if err := WriteVarInt(buf, int(handshake.ProtocolVersion)); err != nil {
+		return err
+	}
+	if err := WriteString(buf, handshake.ServerAddress); err != nil {
+		return err
+	}
+	if err := binary.Write(buf, binary.BigEndian, handshake.ServerPort); err != nil {
+		return err
+	}
+	if err := WriteVarInt(buf, int(handshake.NextState)); err != nil {
+		return err
+	}
+
+	return WritePacket(writer, PacketIdHandshake, buf.Bytes())
+}
+
+// WriteStatusRequest writes a status request packet as sent by a client
+func WriteStatusRequest(writer io.Writer) error {
+	return WritePacket(writer, PacketIdStatusRequest, nil)
+}
+
 // StatusResponse represents the server status response JSON
 type StatusResponse struct {
 	Version struct {
@@ -72,28 +100,35 @@ type StatusResponse struct {
 		Max    int `json:"max"`
 		Online int `json:"online"`
 	} `json:"players"`
-	Description struct {
-		Text string `json:"text"`
-	} `json:"description"`
+	Description *TextComponent `json:"description"`
+	// Favicon is a data URI of a 64x64 PNG image
+	Favicon string `json:"favicon,omitempty"`
 }
 
-// WriteStatusResponse writes a status response packet
-func WriteStatusResponse(writer io.Writer, motd string, maxPlayers, onlinePlayers int, version string, protocol int) error {
+// WriteStatusResponse writes a status response packet.
+// The favicon is optional and, when non-empty, must be a data URI of a 64x64 PNG image.
+func WriteStatusResponse(writer io.Writer, motd *TextComponent, favicon string, maxPlayers, onlinePlayers int, version string, protocol int) error {
 	response := StatusResponse{}
 	response.Version.Name = version
 	response.Version.Protocol = protocol
 	response.Players.Max = maxPlayers
 	response.Players.Online = onlinePlayers
-	response.Description.Text = motd
+	response.Description = motd
+	response.Favicon = favicon
 
 	jsonData, err := json.Marshal(response)
 	if err != nil {
 		return err
 	}
 
+	return WriteStatusResponseJSON(writer, jsonData)
+}
+
+// WriteStatusResponseJSON writes a status response packet containing the given status JSON as is
+func WriteStatusResponseJSON(writer io.Writer, status []byte) error {
 	// Packet ID for Status Response is 0x00 in status state
 	buf := new(bytes.Buffer)
-	if err := WriteString(buf, string(jsonData)); err != nil {
+	if err := WriteString(buf, string(status)); err != nil {
 		return err
 	}
 
@@ -112,15 +147,66 @@ func WritePong(writer io.Writer, payload int64) error {
 }
 
 // WriteDisconnect writes a disconnect packet during login state
-func WriteDisconnect(writer io.Writer, reason string) error {
-	// Create JSON chat component for disconnect reason
-	reasonJSON := fmt.Sprintf(`{"text":"%s"}`, reason)
+func WriteDisconnect(writer io.Writer, reason *TextComponent) error {
+	reasonJSON, err := json.Marshal(reason)
+	if err != nil {
+		return err
+	}
 
 	buf := new(bytes.Buffer)
-	if err := WriteString(buf, reasonJSON); err != nil {
+	if err := WriteString(buf, string(reasonJSON)); err != nil {
 		return err
 	}
 
 	// Packet ID for Disconnect (login) is 0x00 in login state
 	return WritePacket(writer, 0x00, buf.Bytes())
 }
+
+// WriteLegacyServerListPingResponse writes the kick packet that pre-1.7 clients expect in response
+// to a legacy server list ping. The format depends on the variant of the ping that was received.
+func WriteLegacyServerListPingResponse(writer io.Writer, variant LegacyPingVariant,
+	motd *TextComponent, maxPlayers, onlinePlayers int, version string, protocol int) error {
+
+	var response string
+	switch variant {
+	case LegacyPingBeta:
+		// Fields are separated by the formatting character, so it can't appear in the MOTD
+		description := strings.ReplaceAll(motd.PlainText(), string(legacyFormattingChar), "")
+		response = strings.Join([]string{
+			description,
+			strconv.Itoa(onlinePlayers),
+			strconv.Itoa(maxPlayers),
+		}, string(legacyFormattingChar))
+	default:
+		response = strings.Join([]string{
+			string(legacyFormattingChar) + "1",
+			strconv.Itoa(protocol),
+			version,
+			motd.LegacyText(),
+			strconv.Itoa(onlinePlayers),
+			strconv.Itoa(maxPlayers),
+		}, "\x00")
+	}
+
+	return WriteLegacyKick(writer, response)
+}
+
+// WriteLegacyKick writes a pre-1.7 kick packet, which is the packet ID followed by the
+// length prefixed UTF-16BE reason
+func WriteLegacyKick(writer io.Writer, reason string) error {
+	encoded, _, err := transform.Bytes(unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder(), []byte(reason))
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteByte(PacketIdLegacyKick)
+	// Length is declared in UTF-16 code units
+	if err := binary.Write(buf, binary.BigEndian, uint16(len(encoded)/2)); err != nil {
+		return err
+	}
+	buf.Write(encoded)
+
+	_, err = writer.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultJoinAttemptsLimit is how many join attempts are listed unless a limit is given
+const defaultJoinAttemptsLimit = 20
+
+// HostStatus is the state of a host as reported by the admin API
+type HostStatus struct {
+	Name  string      `json:"name"`
+	State ServerState `json:"state"`
+	// Forced is set when the state was forced through the admin API
+	Forced      bool                   `json:"forced"`
+	Motd        string                 `json:"motd"`
+	KickMessage string                 `json:"kickMessage"`
+	Motds       map[ServerState]string `json:"motds"`
+}
+
+type stateRequest struct {
+	State string `json:"state"`
+}
+
+type motdRequest struct {
+	Motd string `json:"motd"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// adminApi serves the authenticated REST API to inspect and control the state of each host
+type adminApi struct {
+	connector *Connector
+}
+
+func (a *adminApi) register(mux *http.ServeMux) {
+	mux.Handle("GET /api/hosts", a.authenticated(a.listHosts))
+	mux.Handle("GET /api/hosts/{host}", a.authenticated(a.getHost))
+	mux.Handle("PUT /api/hosts/{host}/state", a.authenticated(a.setState))
+	mux.Handle("DELETE /api/hosts/{host}/state", a.authenticated(a.clearState))
+	mux.Handle("PUT /api/hosts/{host}/motd/{state}", a.authenticated(a.setMotd))
+	mux.Handle("DELETE /api/hosts/{host}/motd/{state}", a.authenticated(a.clearMotd))
+	mux.Handle("GET /api/join-attempts", a.authenticated(a.listJoinAttempts))
+}
+
+// authenticated requires the configured token as a bearer token in the Authorization header.
+// The API is disabled while no token is configured.
+func (a *adminApi) authenticated(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := a.connector.config.Load().Http.Token
+		if expected == "" {
+			writeJson(w, http.StatusNotFound, &apiError{Error: "the admin API is disabled, no token is configured"})
+			return
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJson(w, http.StatusUnauthorized, &apiError{Error: "invalid or missing token"})
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func (a *adminApi) listHosts(w http.ResponseWriter, _ *http.Request) {
+	hosts := a.connector.hosts.Load().all()
+	statuses := make([]*HostStatus, 0, len(hosts))
+	for _, host := range hosts {
+		statuses = append(statuses, hostStatus(host))
+	}
+	writeJson(w, http.StatusOK, statuses)
+}
+
+func (a *adminApi) getHost(w http.ResponseWriter, r *http.Request) {
+	if host := a.lookupHost(w, r); host != nil {
+		writeJson(w, http.StatusOK, hostStatus(host))
+	}
+}
+
+func (a *adminApi) setState(w http.ResponseWriter, r *http.Request) {
+	host := a.lookupHost(w, r)
+	if host == nil {
+		return
+	}
+
+	var request stateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJson(w, http.StatusBadRequest, &apiError{Error: "invalid request body"})
+		return
+	}
+	state, err := ParseServerState(request.State)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, &apiError{Error: err.Error()})
+		return
+	}
+
+	host.motdManager.SetState(state)
+	logrus.WithField("host", host).WithField("state", state).Info("State set through the admin API")
+	writeJson(w, http.StatusOK, hostStatus(host))
+}
+
+func (a *adminApi) clearState(w http.ResponseWriter, r *http.Request) {
+	if host := a.lookupHost(w, r); host != nil {
+		host.motdManager.ClearState()
+		logrus.WithField("host", host).Info("Forced state cleared through the admin API")
+		writeJson(w, http.StatusOK, hostStatus(host))
+	}
+}
+
+func (a *adminApi) setMotd(w http.ResponseWriter, r *http.Request) {
+	host, state := a.lookupHostState(w, r)
+	if host == nil {
+		return
+	}
+
+	var request motdRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJson(w, http.StatusBadRequest, &apiError{Error: "invalid request body"})
+		return
+	}
+
+	host.motdManager.SetMOTD(state, request.Motd)
+	logrus.WithField("host", host).WithField("state", state).Info("MOTD set through the admin API")
+	writeJson(w, http.StatusOK, hostStatus(host))
+}
+
+func (a *adminApi) clearMotd(w http.ResponseWriter, r *http.Request) {
+	if host, state := a.lookupHostState(w, r); host != nil {
+		host.motdManager.SetMOTD(state, "")
+		writeJson(w, http.StatusOK, hostStatus(host))
+	}
+}
+
+func (a *adminApi) listJoinAttempts(w http.ResponseWriter, r *http.Request) {
+	limit := defaultJoinAttemptsLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeJson(w, http.StatusBadRequest, &apiError{Error: "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+	writeJson(w, http.StatusOK, a.connector.joinAttempts.recent(limit))
+}
+
+// lookupHost returns the host named in the path or answers with an error and returns nil
+func (a *adminApi) lookupHost(w http.ResponseWriter, r *http.Request) *virtualHost {
+	host := a.connector.hosts.Load().get(r.PathValue("host"))
+	if host == nil {
+		writeJson(w, http.StatusNotFound, &apiError{Error: "unknown host"})
+	}
+	return host
+}
+
+// lookupHostState returns the host and state named in the path or answers with an error and
+// returns a nil host
+func (a *adminApi) lookupHostState(w http.ResponseWriter, r *http.Request) (*virtualHost, ServerState) {
+	host := a.lookupHost(w, r)
+	if host == nil {
+		return nil, ""
+	}
+	state, err := ParseServerState(r.PathValue("state"))
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, &apiError{Error: err.Error()})
+		return nil, ""
+	}
+	return host, state
+}
+
+func hostStatus(host *virtualHost) *HostStatus {
+	return &HostStatus{
+		Name:        host.String(),
+		State:       host.motdManager.GetCurrentState(),
+		Forced:      host.motdManager.ForcedState() != "",
+		Motd:        host.motdManager.GetCurrentMOTD(),
+		KickMessage: host.motdManager.GetCurrentKickMessage(),
+		Motds:       host.motdManager.MOTDs(),
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		logrus.WithError(err).Debug("Failed to write HTTP response")
+	}
+}
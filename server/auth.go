@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/wroud/mc-motd/mcproto"
+)
+
+const (
+	// authTimeout allows for the client contacting the session server before it responds
+	authTimeout = 30 * time.Second
+	// serverKeyBits is the size of the RSA key a vanilla server uses
+	serverKeyBits = 1024
+	// sharedSecretLength is the size of the AES key chosen by the client
+	sharedSecretLength = 16
+)
+
+type serverKey struct {
+	private   *rsa.PrivateKey
+	publicDER []byte
+}
+
+// loadServerKey generates the RSA key pair used for encryption during login once per process
+var loadServerKey = sync.OnceValues(func() (*serverKey, error) {
+	private, err := rsa.GenerateKey(rand.Reader, serverKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &serverKey{private: private, publicDER: publicDER}, nil
+})
+
+var sessionClient = &http.Client{Timeout: 10 * time.Second}
+
+// encryptedConn encrypts and decrypts everything sent over the connection once encryption
+// has been enabled during login
+type encryptedConn struct {
+	net.Conn
+	reader io.Reader
+	writer io.Writer
+}
+
+func (c *encryptedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *encryptedConn) Write(p []byte) (int, error) {
+	return c.writer.Write(p)
+}
+
+// sessionProfile is the profile returned by the session server for a player that has joined
+type sessionProfile struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// authenticatePlayer enables encryption and verifies the player with the session server, the same
+// as a server in online mode. Once encryption is enabled, the returned connection and reader must
+// be used for the rest of the login, even if verification then fails.
+func (c *Connector) authenticatePlayer(frontendConn net.Conn, clientAddr net.Addr, handshake *mcproto.Handshake,
+	host *virtualHost, playerInfo *PlayerInfo, bufferedReader *bufio.Reader) (net.Conn, *bufio.Reader, *PlayerInfo, error) {
+
+	if playerInfo == nil {
+		return nil, nil, nil, fmt.Errorf("login start was not received")
+	}
+
+	key, err := loadServerKey()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to generate server key: %w", err)
+	}
+
+	verifyToken := make([]byte, 4)
+	if _, err := rand.Read(verifyToken); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if err := mcproto.WriteEncryptionRequest(frontendConn, handshake.ProtocolVersion, "", key.publicDER, verifyToken); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to write encryption request: %w", err)
+	}
+	if err := frontendConn.SetReadDeadline(time.Now().Add(authTimeout)); err != nil {
+		return nil, nil, nil, err
+	}
+
+	packet, err := mcproto.ReadPacket(bufferedReader, clientAddr, mcproto.StateLogin)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read encryption response: %w", err)
+	}
+	if packet.PacketID != mcproto.PacketIdEncryptionResponse {
+		return nil, nil, nil, fmt.Errorf("expected encryption response, got packet %d", packet.PacketID)
+	}
+	response, err := mcproto.DecodeEncryptionResponse(handshake.ProtocolVersion, packet.Data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if response.VerifyToken == nil {
+		return nil, nil, nil, fmt.Errorf("signed nonces are not supported")
+	}
+
+	sharedSecret, err := rsa.DecryptPKCS1v15(rand.Reader, key.private, response.SharedSecret)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decrypt shared secret: %w", err)
+	}
+	// The secret is both the AES-128 key and the IV of CFB8
+	if len(sharedSecret) != sharedSecretLength {
+		return nil, nil, nil, fmt.Errorf("shared secret has %d bytes instead of %d", len(sharedSecret), sharedSecretLength)
+	}
+	decryptedToken, err := rsa.DecryptPKCS1v15(rand.Reader, key.private, response.VerifyToken)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decrypt verify token: %w", err)
+	}
+	if len(decryptedToken) != len(verifyToken) || subtle.ConstantTimeCompare(decryptedToken, verifyToken) != 1 {
+		return nil, nil, nil, fmt.Errorf("verify token does not match")
+	}
+
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid shared secret: %w", err)
+	}
+
+	// Anything the client sent after the encryption response is already encrypted
+	buffered, _ := bufferedReader.Peek(bufferedReader.Buffered())
+	conn := &encryptedConn{
+		Conn: frontendConn,
+		reader: &cipher.StreamReader{
+			S: mcproto.NewCFB8Decrypter(block, sharedSecret),
+			R: io.MultiReader(bytes.NewReader(buffered), frontendConn),
+		},
+		writer: &cipher.StreamWriter{
+			S: mcproto.NewCFB8Encrypter(block, sharedSecret),
+			W: frontendConn,
+		},
+	}
+	reader := bufio.NewReader(conn)
+
+	serverHash := mcproto.AuthDigest("", sharedSecret, key.publicDER)
+	profile, err := hasJoined(c.ctx, host.config.Auth.SessionUrl, playerInfo.Name, serverHash)
+	if err != nil {
+		return conn, reader, nil, err
+	}
+
+	playerUuid, err := uuid.Parse(profile.Id)
+	if err != nil {
+		return conn, reader, nil, fmt.Errorf("session server returned invalid uuid %q: %w", profile.Id, err)
+	}
+
+	verified := &PlayerInfo{
+		Name:        profile.Name,
+		Uuid:        playerUuid,
+		Transferred: playerInfo.Transferred,
+		Verified:    true,
+	}
+	logrus.
+		WithField("client", clientAddr).
+		WithField("player", verified).
+		Info("Verified player with session server")
+
+	return conn, reader, verified, nil
+}
+
+// hasJoined asks the session server whether the player has joined the server identified by
+// the hash, which only succeeds if the client owns the account
+func hasJoined(ctx context.Context, sessionUrl, name, serverHash string) (*sessionProfile, error) {
+	requestUrl, err := url.Parse(sessionUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session url: %w", err)
+	}
+	query := requestUrl.Query()
+	query.Set("username", name)
+	query.Set("serverId", serverHash)
+	requestUrl.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sessionClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact session server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, fmt.Errorf("player %s has not joined according to the session server", name)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("session server responded with status %d", resp.StatusCode)
+	}
+
+	profile := &sessionProfile{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(profile); err != nil {
+		return nil, fmt.Errorf("invalid session server response: %w", err)
+	}
+	if profile.Id == "" || profile.Name == "" {
+		return nil, fmt.Errorf("session server response is missing the profile")
+	}
+	return profile, nil
+}
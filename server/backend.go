@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/wroud/mc-motd/mcproto"
+)
+
+const backendPingTimeout = 5 * time.Second
+
+// HealthcheckServerAddress is the server address in the handshake of health check pings, which
+// are answered without passthrough, metrics or notifications when they come from a loopback address
+const HealthcheckServerAddress = "mc-motd.healthcheck"
+
+// proxyHeaderUnknown is a PROXY protocol v1 header without a client address, so that the address
+// of the connection is used
+const proxyHeaderUnknown = "PROXY UNKNOWN\r\n"
+
+// PingBackend sends a status request to the Minecraft server at the given host:port address
+// and returns the JSON status it responded with.
+func PingBackend(ctx context.Context, address string) (json.RawMessage, error) {
+	return ping(ctx, address, "", nil)
+}
+
+// PingHealthcheck status pings the mc-motd listener at the given host:port address, starting
+// with a PROXY protocol header if the listener expects one from this connection
+func PingHealthcheck(ctx context.Context, address string, proxyProtocol *ProxyProtocolConfig) error {
+	_, err := ping(ctx, address, HealthcheckServerAddress, proxyProtocol)
+	return err
+}
+
+// ping sends a status request with the server address in the handshake, defaulting to the
+// host of the address. With an enabled proxy protocol configuration, a PROXY header is sent
+// first if the listener trusts the local address of the connection.
+func ping(ctx context.Context, address string, serverAddress string, proxyProtocol *ProxyProtocolConfig) (json.RawMessage, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend address %s: %w", address, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backend port %s: %w", portStr, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, backendPingTimeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if proxyProtocol != nil && proxyProtocol.Enabled {
+		trustedProxies, err := parsePrefixes(proxyProtocol.TrustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+		}
+		ip, ok := clientIp(conn.LocalAddr())
+		if ok && containsAddr(trustedProxies, ip) {
+			if _, err := conn.Write([]byte(proxyHeaderUnknown)); err != nil {
+				return nil, fmt.Errorf("failed to write proxy protocol header: %w", err)
+			}
+		}
+	}
+
+	if serverAddress == "" {
+		serverAddress = host
+	}
+	err = mcproto.WriteHandshake(conn, &mcproto.Handshake{
+		// Any protocol version is accepted for status requests
+		ProtocolVersion: mcproto.ProtocolVersion1_21_7,
+		ServerAddress:   serverAddress,
+		ServerPort:      uint16(port),
+		NextState:       mcproto.StateStatus,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write handshake: %w", err)
+	}
+	if err := mcproto.WriteStatusRequest(conn); err != nil {
+		return nil, fmt.Errorf("failed to write status request: %w", err)
+	}
+
+	packet, err := mcproto.ReadPacket(bufio.NewReader(conn), conn.RemoteAddr(), mcproto.StateStatus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read status response: %w", err)
+	}
+	if packet.PacketID != mcproto.PacketIdStatusResponse {
+		return nil, fmt.Errorf("expected status response, got packet %d", packet.PacketID)
+	}
+
+	status, err := mcproto.DecodeStatusResponse(packet.Data)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(status) {
+		return nil, fmt.Errorf("backend responded with invalid status json")
+	}
+	return status, nil
+}
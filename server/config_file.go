@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wroud/mc-motd/configfile"
+)
+
+// ConfigLoader layers a YAML, TOML or JSON configuration file over the configuration given by
+// flags and environment variables, so that the file can be re-read without losing those values.
+type ConfigLoader struct {
+	path string
+	base []byte
+}
+
+func NewConfigLoader(path string, base *Config) (*ConfigLoader, error) {
+	baseData, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConfigLoader{
+		path: path,
+		base: baseData,
+	}, nil
+}
+
+// Load reads the configuration file and returns the resulting configuration once it has
+// been validated.
+func (l *ConfigLoader) Load() (*Config, error) {
+	config := &Config{}
+	if err := json.Unmarshal(l.base, config); err != nil {
+		return nil, err
+	}
+
+	if err := configfile.Load(l.path, config); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Watch polls the configuration file at the given interval and sends to changed when its
+// modification time or size changes. It returns when the context is done.
+func (l *ConfigLoader) Watch(ctx context.Context, interval time.Duration, changed chan<- struct{}) {
+	watchFiles(ctx, interval, func() {
+		logrus.WithField("file", l.path).Debug("Configuration file changed")
+		select {
+		case changed <- struct{}{}:
+		default:
+			// a reload is already pending
+		}
+	}, newWatchedFile(l.path))
+}
@@ -1,19 +1,214 @@
 package server
 
-import "github.com/wroud/mc-motd/mcproto"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/wroud/mc-motd/mcproto"
+)
 
 type WebhookConfig struct {
-	Url         string `usage:"If set, a POST request that contains connection status notifications will be sent to this HTTP address"`
-	RequireUser bool   `default:"false" usage:"Indicates if the webhook will only be called if a user is connecting rather than just server list/ping"`
+	Url         string         `usage:"If set, a POST request that contains connection status notifications will be sent to this HTTP address"`
+	RequireUser bool           `default:"false" usage:"Deprecated: the webhook at Url is only called for join attempts. Select the status event of a target to receive server list pings"`
+	Secret      string         `usage:"If set, webhook requests are signed with this shared secret, see VerifyWebhookSignature"`
+	SecretFile  string         `usage:"A [file] containing the secret webhook requests are signed with"`
+	Targets     WebhookTargets `usage:"A JSON object mapping [names] to further webhook targets, each with its own event filter, e.g. {\"analytics\":{\"Url\":\"https://example.com/events\",\"Timeout\":5}}"`
+}
+
+// targets returns the configured webhook targets, including the one given by Url
+func (w *WebhookConfig) targets() map[string]*WebhookTargetConfig {
+	targets := make(map[string]*WebhookTargetConfig, len(w.Targets)+1)
+	for name, target := range w.Targets {
+		targets[name] = target
+	}
+	if w.Url != "" {
+		// Status requests are only sent to targets selecting them, so that server list pings
+		// don't each cause a request
+		targets["default"] = &WebhookTargetConfig{
+			Url:        w.Url,
+			Events:     []string{WebhookFilterLogin, WebhookFilterConnect},
+			Secret:     w.Secret,
+			SecretFile: w.SecretFile,
+		}
+	}
+	return targets
+}
+
+// WebhookTargetConfig configures one of the webhook targets events are sent to
+type WebhookTargetConfig struct {
+	Url string
+	// Events filters the events sent to the target: status, login, connect, denied or state. All
+	// events are sent if none are given.
+	Events  []string
+	Headers map[string]string
+	// Timeout is the number of seconds to wait for a response, defaulting to 30
+	Timeout    int
+	Secret     string
+	SecretFile string
+	// Template is a text/template rendering the request body from WebhookTemplateData instead
+	// of the JSON payload
+	Template string
+	// Preset is a built-in template: discord, slack or teams
+	Preset string
+	// ContentType of the request body, defaulting to application/json
+	ContentType string
+}
+
+// secret returns the webhook signing secret, reading it from the secret file if one is given.
+// Requests aren't signed when it is empty.
+func (w *WebhookTargetConfig) secret() ([]byte, error) {
+	if w.SecretFile == "" {
+		return []byte(w.Secret), nil
+	}
+	data, err := os.ReadFile(w.SecretFile)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+func (w *WebhookTargetConfig) validate() error {
+	if _, err := url.ParseRequestURI(w.Url); err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	for _, event := range w.Events {
+		if !slices.Contains(webhookFilters, strings.ToLower(event)) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	if w.Timeout < 0 {
+		return fmt.Errorf("timeout can't be negative")
+	}
+	if _, err := w.template(); err != nil {
+		return err
+	}
+	if w.SecretFile != "" {
+		secret, err := w.secret()
+		if err != nil {
+			return fmt.Errorf("unable to read secret: %w", err)
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("secret file %s is empty", w.SecretFile)
+		}
+	}
+	return nil
+}
+
+// WebhookTargets maps names, used in logs, to webhook targets
+type WebhookTargets map[string]*WebhookTargetConfig
+
+// UnmarshalText allows webhook targets to be declared as a JSON object in a flag or environment variable
+func (w *WebhookTargets) UnmarshalText(text []byte) error {
+	if err := w.UnmarshalJSON(text); err != nil {
+		return fmt.Errorf("webhook targets must be a JSON object keyed by name: %w", err)
+	}
+	return nil
+}
+
+func (w *WebhookTargets) UnmarshalJSON(data []byte) error {
+	var targets map[string]*WebhookTargetConfig
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return err
+	}
+	*w = targets
+	return nil
+}
+
+// ConfigElementType declares that entries of a configuration file's targets section are WebhookTargetConfig
+func (w WebhookTargets) ConfigElementType() reflect.Type {
+	return reflect.TypeOf(WebhookTargetConfig{})
+}
+
+func (w WebhookTargets) String() string {
+	if w == nil {
+		return ""
+	}
+	data, _ := json.Marshal(map[string]*WebhookTargetConfig(w))
+	return string(data)
 }
 
 type ServerStatusConfig struct {
-	SleepingMOTD    string `default:"🌙 Server sleeping, join to wake up!" usage:"The MOTD displayed when the server is in sleeping state"`
-	StartingMOTD    string `default:"⚡ Server starting up..." usage:"The MOTD displayed when the server is starting up"`
-	StartingTimeout int    `default:"300" usage:"How many seconds to show the starting MOTD after a join attempt (default: 5 minutes)"`
-	MaxPlayers      int    `default:"20" usage:"The maximum number of players displayed in the server list"`
-	Version         string `default:"1.21.8" usage:"The Minecraft version displayed in the server list"`
-	Protocol        int    `default:"0" usage:"The protocol version number. If 0 (default), will be auto-detected from Version. Set explicitly to override (e.g., 772 for 1.21.8, 770 for 1.21.5)"`
+	SleepingMOTD        string `default:"🌙 Server sleeping, join to wake up!" usage:"The MOTD displayed when the server is in sleeping state"`
+	StartingMOTD        string `default:"⚡ Server starting up..." usage:"The MOTD displayed when the server is starting up"`
+	RunningMOTD         string `default:"✅ Server is online!" usage:"The MOTD displayed when the state provider reports the server as running"`
+	StoppingMOTD        string `default:"💤 Server is shutting down..." usage:"The MOTD displayed when the state provider reports the server as stopping"`
+	CrashedMOTD         string `default:"⚠ Server crashed, join to restart it" usage:"The MOTD displayed when the state provider reports the server as crashed"`
+	KickMessage         string `default:"🚀 Server is waking up! Please try again in a few minutes." usage:"The message shown to players when they are disconnected after a join attempt while the server is sleeping or starting"`
+	RunningKickMessage  string `default:"✅ Server is online! Please reconnect." usage:"The message shown to players when they are disconnected while the server is running"`
+	StoppingKickMessage string `default:"💤 Server is shutting down, please try again in a minute." usage:"The message shown to players when they are disconnected while the server is stopping"`
+	CrashedKickMessage  string `default:"⚠ Server crashed and is being restarted. Please try again in a few minutes." usage:"The message shown to players when they are disconnected while the server is crashed"`
+	StartingTimeout     int    `default:"300" usage:"How many seconds to show the starting MOTD after a join attempt (default: 5 minutes)"`
+	MaxPlayers          int    `default:"20" usage:"The maximum number of players displayed in the server list"`
+	Version             string `default:"1.21.8" usage:"The Minecraft version displayed in the server list"`
+	Protocol            int    `default:"0" usage:"The protocol version number. If 0 (default), will be auto-detected from Version. Set explicitly to override (e.g., 772 for 1.21.8, 770 for 1.21.5)"`
+	Favicon             string `usage:"Path to a 64x64 PNG [file] displayed as the server icon in the server list"`
+	SleepingFavicon     string `usage:"Path to a 64x64 PNG [file] displayed while the server is sleeping. Defaults to Favicon"`
+	StartingFavicon     string `usage:"Path to a 64x64 PNG [file] displayed while the server is starting up. Defaults to Favicon"`
+}
+
+// motdFor returns the MOTD configured for the given state
+func (c *ServerStatusConfig) motdFor(state ServerState) string {
+	switch state {
+	case StateStarting:
+		return c.StartingMOTD
+	case StateRunning:
+		return c.RunningMOTD
+	case StateStopping:
+		return c.StoppingMOTD
+	case StateCrashed:
+		return c.CrashedMOTD
+	default:
+		return c.SleepingMOTD
+	}
+}
+
+// kickMessageFor returns the disconnect message configured for the given state
+func (c *ServerStatusConfig) kickMessageFor(state ServerState) string {
+	switch state {
+	case StateRunning:
+		return c.RunningKickMessage
+	case StateStopping:
+		return c.StoppingKickMessage
+	case StateCrashed:
+		return c.CrashedKickMessage
+	default:
+		return c.KickMessage
+	}
+}
+
+// loadFavicons returns the encoded favicon of each state, falling back to Favicon for any state
+// that does not declare its own.
+func (c *ServerStatusConfig) loadFavicons() (map[ServerState]string, error) {
+	paths := map[ServerState]string{
+		StateSleeping: c.SleepingFavicon,
+		StateStarting: c.StartingFavicon,
+		StateRunning:  "",
+		StateStopping: "",
+		StateCrashed:  "",
+	}
+
+	favicons := make(map[ServerState]string, len(paths))
+	for state, path := range paths {
+		if path == "" {
+			path = c.Favicon
+		}
+		if path == "" {
+			continue
+		}
+		favicon, err := LoadFavicon(path)
+		if err != nil {
+			return nil, err
+		}
+		favicons[state] = favicon
+	}
+	return favicons, nil
 }
 
 // GetProtocol returns the protocol version to use.
@@ -35,8 +230,496 @@ func (c *ServerStatusConfig) GetProtocol() int {
 	return 772
 }
 
+type BackendConfig struct {
+	Address     string `usage:"The [host:port] of the Minecraft server this placeholder stands in for"`
+	Passthrough bool   `default:"false" usage:"Proxy connections to the backend server while it is running instead of answering them. Uses the ping state provider if no other is configured"`
+	// StatusSnapshot names the file the mirrored status is kept in
+	StatusSnapshot   string `usage:"The [file] the last status of the backend server is saved to. While the backend is down, it is served with the current MOTD as description"`
+	SnapshotInterval int    `default:"60" usage:"How many seconds between status pings of the backend server for the status snapshot"`
+}
+
+type StateProviderConfig struct {
+	Type     string `usage:"How the actual state of the server is determined: http, file, ping or docker. If not set, the starting state is shown for StartingTimeout after a join attempt"`
+	Url      string `usage:"The [URL] polled by the http provider. It should respond with sleeping, starting, running, stopping or crashed as plain text or as JSON {\"state\":\"running\"}"`
+	File     string `usage:"The [file] read by the file provider. It should contain sleeping, starting, running, stopping or crashed"`
+	Interval int    `default:"5" usage:"How many seconds between checks of the server state"`
+}
+
+type LimboConfig struct {
+	Enabled         bool   `default:"false" usage:"Hold 1.20.5+ players in an empty world after a join attempt and transfer them to the server once it is running, instead of disconnecting them. Requires a state provider"`
+	TransferAddress string `usage:"The [host:port] players are transferred to once the server is running. Defaults to the address they connected to, which requires passthrough"`
+	Timeout         int    `default:"600" usage:"How many seconds a player is held before being disconnected with the kick message"`
+	ProgressMessage string `default:"<gray>Waiting for the server to start... <white>{elapsed}s" usage:"The action bar text shown while waiting, where {elapsed} is replaced by the number of seconds waited so far"`
+}
+
+const (
+	TransferPolicyAccept = "accept"
+	TransferPolicyReject = "reject"
+)
+
+type TransferConfig struct {
+	Policy      string `default:"accept" usage:"Whether players arriving through a Transfer packet from another server are handled like any other login or disconnected: accept or reject"`
+	KickMessage string `default:"Transfers to this server are not allowed." usage:"The message shown to transferred players when transfers are rejected"`
+}
+
+func (t *TransferConfig) accepts() bool {
+	return !strings.EqualFold(t.Policy, TransferPolicyReject)
+}
+
+type AuthConfig struct {
+	OnlineMode  bool   `default:"false" usage:"Verify players with the session server, like a server in online mode, before counting their join attempt. Players that can't be verified are disconnected without waking the server"`
+	SessionUrl  string `default:"https://sessionserver.mojang.com/session/minecraft/hasJoined" usage:"The hasJoined [URL] of the session server used to verify players"`
+	KickMessage string `default:"Failed to verify username!" usage:"The message shown to players that could not be verified"`
+}
+
+type PlayersConfig struct {
+	Allow       []string `usage:"Player [names or UUIDs] allowed to wake the server. If no allowlist is given, every player that isn't denied is allowed"`
+	AllowFile   string   `usage:"A vanilla whitelist.json [file] of players allowed to wake the server, reloaded when it changes"`
+	Deny        []string `usage:"Player [names or UUIDs] that can't wake the server"`
+	DenyFile    string   `usage:"A vanilla banned-players.json [file] of players that can't wake the server, reloaded when it changes"`
+	KickMessage string   `default:"You are not allowed to wake this server." usage:"The message shown to players that are not allowed to wake the server"`
+}
+
+// enabled reports whether any allowlist or denylist is configured
+func (p *PlayersConfig) enabled() bool {
+	return len(p.Allow) > 0 || p.AllowFile != "" || len(p.Deny) > 0 || p.DenyFile != ""
+}
+
+type ExecConfig struct {
+	Command      string   `usage:"A shell [command] run on the selected events, such as systemctl start mc@pack. The event is given in MC_MOTD_ environment variables and as JSON on stdin"`
+	Events       []string `default:"login" override-value:"true" usage:"The [events] the command is run on: status, login, connect, denied or state"`
+	Timeout      int      `default:"60" usage:"How many seconds the command may run before it is killed"`
+	FailureState string   `usage:"The [state] the server is set to when the command fails, such as crashed, until it succeeds. Left unchanged if not set"`
+}
+
+func (e *ExecConfig) validate() error {
+	if e.Command == "" {
+		return nil
+	}
+	for _, event := range e.Events {
+		if !slices.Contains(webhookFilters, strings.ToLower(event)) {
+			return fmt.Errorf("unknown exec event %q", event)
+		}
+	}
+	if e.Timeout <= 0 {
+		return fmt.Errorf("exec timeout must be positive")
+	}
+	if e.FailureState != "" {
+		if _, err := ParseServerState(e.FailureState); err != nil {
+			return fmt.Errorf("exec failure state: %w", err)
+		}
+	}
+	return nil
+}
+
+type DockerConfig struct {
+	Container string   `usage:"The name or ID of the Docker [container] started on join attempts. Its state and health also determine the server state unless another state provider is configured"`
+	Socket    string   `default:"/var/run/docker.sock" usage:"The unix [socket] of the Docker Engine API"`
+	Events    []string `default:"login" override-value:"true" usage:"The [events] the container is started on: status, login, connect, denied or state"`
+	Timeout   int      `default:"30" usage:"How many seconds to wait for the Docker Engine API to respond"`
+}
+
+func (d *DockerConfig) validate() error {
+	if d.Container == "" {
+		return nil
+	}
+	for _, event := range d.Events {
+		if !slices.Contains(webhookFilters, strings.ToLower(event)) {
+			return fmt.Errorf("unknown docker event %q", event)
+		}
+	}
+	if d.Timeout <= 0 {
+		return fmt.Errorf("docker timeout must be positive")
+	}
+	return nil
+}
+
+// HostConfig declares the settings that can be given separately for each virtual host
+type HostConfig struct {
+	Webhook       WebhookConfig       `usage:"Webhook configuration"`
+	ServerStatus  ServerStatusConfig  `usage:"Server status configuration for server list responses"`
+	Backend       BackendConfig       `usage:"Backend server configuration"`
+	StateProvider StateProviderConfig `usage:"State provider configuration. The ping provider sends status requests to Backend.Address"`
+	Limbo         LimboConfig         `usage:"Limbo configuration for holding players until the server is running"`
+	Transfer      TransferConfig      `usage:"Configuration of players arriving through a Transfer packet"`
+	Auth          AuthConfig          `usage:"Player authentication configuration"`
+	Players       PlayersConfig       `usage:"Configuration of the players allowed to wake the server"`
+	Exec          ExecConfig          `usage:"Configuration of a command run on server events"`
+	Docker        DockerConfig        `usage:"Configuration of a Docker container started on join attempts"`
+}
+
+// stateProviderType returns the configured state provider type, defaulting to the docker provider
+// when a container is configured and to the ping provider when connections are passed through to
+// the backend
+func (h *HostConfig) stateProviderType() string {
+	providerType := strings.ToLower(h.StateProvider.Type)
+	if providerType == "" && h.Docker.Container != "" {
+		return StateProviderDocker
+	}
+	if providerType == "" && h.Backend.Passthrough {
+		return StateProviderPing
+	}
+	return providerType
+}
+
+// VirtualHosts maps hostnames, as given by clients in the handshake, to their configuration.
+// Each entry is kept as raw JSON so that it can be layered over the default HostConfig
+// when resolved. A hostname may start with "*." to match any subdomain.
+type VirtualHosts map[string]json.RawMessage
+
+// UnmarshalText allows virtual hosts to be declared as a JSON object in a flag or environment variable
+func (v *VirtualHosts) UnmarshalText(text []byte) error {
+	if err := v.UnmarshalJSON(text); err != nil {
+		return fmt.Errorf("virtual hosts must be a JSON object keyed by hostname: %w", err)
+	}
+	return nil
+}
+
+func (v *VirtualHosts) UnmarshalJSON(data []byte) error {
+	var hosts map[string]json.RawMessage
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return err
+	}
+	*v = hosts
+	return nil
+}
+
+// ConfigElementType declares that entries of a configuration file's hosts section are HostConfig
+func (v VirtualHosts) ConfigElementType() reflect.Type {
+	return reflect.TypeOf(HostConfig{})
+}
+
+func (v VirtualHosts) String() string {
+	if v == nil {
+		return ""
+	}
+	data, _ := json.Marshal(map[string]json.RawMessage(v))
+	return string(data)
+}
+
+type IpConfig struct {
+	Allow       []string `usage:"Client [addresses or CIDRs] allowed to connect. If no allowlist is given, every address that isn't denied is allowed"`
+	Deny        []string `usage:"Client [addresses or CIDRs] that can't connect"`
+	DenyFile    string   `usage:"A vanilla banned-ips.json [file] of addresses that can't connect, reloaded when it changes"`
+	Block       []string `default:"status,login" override-value:"true" usage:"The [actions] blocked for addresses that are not allowed: status, login or wake. Denied addresses are closed before reading anything, and so are all others that are not allowed when both status and login are blocked"`
+	KickMessage string   `default:"You are not allowed to join this server." usage:"The message shown to players whose address is not allowed"`
+}
+
+// enabled reports whether any address list is configured
+func (i *IpConfig) enabled() bool {
+	return len(i.Allow) > 0 || len(i.Deny) > 0 || i.DenyFile != ""
+}
+
+func (i *IpConfig) validate() error {
+	for _, action := range i.Block {
+		switch strings.ToLower(action) {
+		case IpBlockStatus, IpBlockLogin, IpBlockWake:
+		default:
+			return fmt.Errorf("unknown ip block action %q", action)
+		}
+	}
+	if _, err := parsePrefixes(i.Allow); err != nil {
+		return fmt.Errorf("ip allow list: %w", err)
+	}
+	if _, err := parsePrefixes(i.Deny); err != nil {
+		return fmt.Errorf("ip deny list: %w", err)
+	}
+	return nil
+}
+
+type RateLimitConfig struct {
+	PerIp          float64 `usage:"Connections per second accepted from each client address other than loopback, or 0 for no limit. IPv6 clients are grouped by /64"`
+	PerIpBurst     int     `default:"10" usage:"How many connections a client address can open at once before its rate limit applies"`
+	Global         float64 `usage:"Connections per second accepted in total, or 0 for no limit"`
+	GlobalBurst    int     `default:"100" usage:"How many connections can be opened at once before the global rate limit applies"`
+	MaxConnections int     `usage:"How many connections can be handled at the same time, including proxied and limbo players, or 0 for no limit"`
+	Excess         string  `default:"close" usage:"What to do with connections beyond the limits: close, or tarpit to hold them open without answering"`
+	TarpitTimeout  int     `default:"30" usage:"How many seconds a tarpitted connection is held open before it is closed"`
+}
+
+func (r *RateLimitConfig) validate() error {
+	if r.PerIp < 0 || r.Global < 0 || r.MaxConnections < 0 {
+		return fmt.Errorf("rate limits can't be negative")
+	}
+	if r.PerIp > 0 && r.PerIpBurst < 1 {
+		return fmt.Errorf("per ip burst must be at least 1")
+	}
+	if r.Global > 0 && r.GlobalBurst < 1 {
+		return fmt.Errorf("global burst must be at least 1")
+	}
+	switch r.Excess {
+	case ExcessClose:
+	case ExcessTarpit:
+		if r.TarpitTimeout <= 0 {
+			return fmt.Errorf("tarpit timeout must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown rate limit excess handling %q", r.Excess)
+	}
+	return nil
+}
+
+type ProxyProtocolConfig struct {
+	Enabled        bool     `usage:"Read a PROXY protocol v1 or v2 header from connections of trusted proxies to learn the real client address"`
+	TrustedProxies []string `usage:"The [addresses or CIDRs] of proxies trusted to send a PROXY protocol header, required when it is enabled"`
+}
+
+func (p *ProxyProtocolConfig) validate() error {
+	trustedProxies, err := parsePrefixes(p.TrustedProxies)
+	if err != nil {
+		return fmt.Errorf("trusted proxies: %w", err)
+	}
+	// Trusting every address would let any client choose the address it is checked by
+	if p.Enabled && len(trustedProxies) == 0 {
+		return fmt.Errorf("the PROXY protocol requires trusted proxies")
+	}
+	return nil
+}
+
+type ForwardingConfig struct {
+	Mode        string `usage:"How the proxy in front forwards player details: bungeecord for legacy IP forwarding or velocity for modern forwarding. If not set, the details sent by clients are used"`
+	Secret      string `usage:"The modern forwarding secret shared with Velocity"`
+	SecretFile  string `usage:"A [file] containing the modern forwarding secret shared with Velocity"`
+	KickMessage string `default:"Unable to verify player details." usage:"The message shown to players whose details were not forwarded by the proxy"`
+}
+
+// secret returns the Velocity forwarding secret, reading it from the secret file if one is given
+func (f *ForwardingConfig) secret() ([]byte, error) {
+	if f.SecretFile == "" {
+		return []byte(f.Secret), nil
+	}
+	data, err := os.ReadFile(f.SecretFile)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(data), nil
+}
+
+func (f *ForwardingConfig) validate() error {
+	switch f.Mode {
+	case "", ForwardingBungeeCord:
+	case ForwardingVelocity:
+		secret, err := f.secret()
+		if err != nil {
+			return fmt.Errorf("unable to read forwarding secret: %w", err)
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("velocity forwarding requires a secret")
+		}
+	default:
+		return fmt.Errorf("unknown forwarding mode %q", f.Mode)
+	}
+	return nil
+}
+
+type WebhookQueueConfig struct {
+	MaxRetries     int    `default:"5" usage:"How many times a webhook request is retried after failing"`
+	RetryDelay     int    `default:"1" usage:"How many seconds to wait before retrying a webhook request, doubling with each further retry"`
+	MaxRetryDelay  int    `default:"60" usage:"The most seconds to wait between retries of a webhook request"`
+	Size           int    `default:"1000" usage:"How many webhook events can wait for delivery before new ones are dropped"`
+	Dir            string `usage:"A [directory] keeping webhook events until they are delivered, so that they are delivered after a restart"`
+	DeadLetterFile string `usage:"A [file] that webhook events which could not be delivered are appended to as JSON lines"`
+}
+
+func (w *WebhookQueueConfig) validate() error {
+	if w.MaxRetries < 0 {
+		return fmt.Errorf("webhook max retries can't be negative")
+	}
+	if w.RetryDelay <= 0 || w.MaxRetryDelay < w.RetryDelay {
+		return fmt.Errorf("webhook retry delays must be positive, with the max retry delay at least the retry delay")
+	}
+	if w.Size <= 0 {
+		return fmt.Errorf("webhook queue size must be positive")
+	}
+	return nil
+}
+
+type HttpConfig struct {
+	Address string `usage:"The [address] of the HTTP listener serving /metrics, /healthz, /readyz and the admin API, such as :8080. Not started if not set"`
+	Token   string `usage:"The bearer [token] required by the admin API. The admin API is disabled if not set"`
+}
+
 type Config struct {
-	Port         int                `default:"25565" usage:"The [port] bound to listen for Minecraft client connections"`
-	Webhook      WebhookConfig      `usage:"Webhook configuration"`
-	ServerStatus ServerStatusConfig `usage:"Server status configuration for server list responses"`
+	Port          int `default:"25565" usage:"The [port] bound to listen for Minecraft client connections"`
+	HostConfig    `flatten:"true"`
+	Ip            IpConfig            `usage:"Client address access control"`
+	RateLimit     RateLimitConfig     `usage:"Connection rate limiting"`
+	ProxyProtocol ProxyProtocolConfig `usage:"PROXY protocol configuration for running behind a load balancer"`
+	Forwarding    ForwardingConfig    `usage:"Player forwarding configuration for running behind BungeeCord or Velocity"`
+	Http          HttpConfig          `usage:"HTTP listener configuration"`
+	WebhookQueue  WebhookQueueConfig  `usage:"Webhook delivery configuration"`
+	Hosts         VirtualHosts        `usage:"A JSON object mapping [hostnames] to a host configuration layered over the default one, e.g. {\"pack.example.com\":{\"ServerStatus\":{\"SleepingMOTD\":\"Pack sleeping\"}}}"`
+}
+
+// Validate checks the configuration for values that can't be used
+func (c *Config) Validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid port %d", c.Port)
+	}
+	if err := c.HostConfig.validate(); err != nil {
+		return err
+	}
+	if err := c.Ip.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := c.ProxyProtocol.validate(); err != nil {
+		return err
+	}
+	if err := c.Forwarding.validate(); err != nil {
+		return err
+	}
+	if err := c.WebhookQueue.validate(); err != nil {
+		return err
+	}
+	if c.Forwarding.Mode != "" && c.HostConfig.Auth.OnlineMode {
+		return fmt.Errorf("online mode can't be used with forwarding, the proxy authenticates players")
+	}
+
+	hosts, err := c.ResolveHosts()
+	if err != nil {
+		return err
+	}
+	for name, hostConfig := range hosts {
+		if err := hostConfig.validate(); err != nil {
+			return fmt.Errorf("host %s: %w", name, err)
+		}
+		if c.Forwarding.Mode != "" && hostConfig.Auth.OnlineMode {
+			return fmt.Errorf("host %s: online mode can't be used with forwarding, the proxy authenticates players", name)
+		}
+	}
+	return nil
+}
+
+func (h *HostConfig) validate() error {
+	if h.ServerStatus.StartingTimeout < 0 {
+		return fmt.Errorf("starting timeout can't be negative")
+	}
+	if h.ServerStatus.MaxPlayers < 0 {
+		return fmt.Errorf("max players can't be negative")
+	}
+	if h.Backend.Address != "" {
+		if _, _, err := net.SplitHostPort(h.Backend.Address); err != nil {
+			return fmt.Errorf("invalid backend address: %w", err)
+		}
+	} else if h.Backend.Passthrough {
+		return fmt.Errorf("passthrough requires a backend address")
+	} else if h.Backend.StatusSnapshot != "" {
+		return fmt.Errorf("status snapshot requires a backend address")
+	}
+	if h.Backend.StatusSnapshot != "" && h.Backend.SnapshotInterval <= 0 {
+		return fmt.Errorf("snapshot interval must be positive")
+	}
+
+	switch h.stateProviderType() {
+	case "":
+	case StateProviderHttp:
+		if h.StateProvider.Url == "" {
+			return fmt.Errorf("the http state provider requires a url")
+		}
+	case StateProviderFile:
+		if h.StateProvider.File == "" {
+			return fmt.Errorf("the file state provider requires a file")
+		}
+	case StateProviderPing:
+		if h.Backend.Address == "" {
+			return fmt.Errorf("the ping state provider requires a backend address")
+		}
+	case StateProviderDocker:
+		if h.Docker.Container == "" {
+			return fmt.Errorf("the docker state provider requires a container")
+		}
+	default:
+		return fmt.Errorf("unknown state provider type %q", h.StateProvider.Type)
+	}
+	if h.stateProviderType() != "" && h.StateProvider.Interval <= 0 {
+		return fmt.Errorf("state provider interval must be positive")
+	}
+
+	if h.Limbo.Enabled {
+		if h.stateProviderType() == "" {
+			return fmt.Errorf("limbo requires a state provider to know when the server is running")
+		}
+		if h.Limbo.Timeout <= 0 {
+			return fmt.Errorf("limbo timeout must be positive")
+		}
+		if h.Limbo.TransferAddress != "" {
+			if _, _, err := net.SplitHostPort(h.Limbo.TransferAddress); err != nil {
+				return fmt.Errorf("invalid limbo transfer address: %w", err)
+			}
+		} else if !h.Backend.Passthrough {
+			// Without passthrough, the transferred player would land in limbo again
+			return fmt.Errorf("limbo transfers players back to the address they connected to, which requires passthrough unless a transfer address is given")
+		} else if !h.Transfer.accepts() {
+			return fmt.Errorf("limbo transfers players back to the address they connected to, which requires accepting transfers")
+		}
+	}
+
+	if h.Auth.OnlineMode {
+		if _, err := url.ParseRequestURI(h.Auth.SessionUrl); err != nil {
+			return fmt.Errorf("invalid session url: %w", err)
+		}
+	}
+
+	switch strings.ToLower(h.Transfer.Policy) {
+	case TransferPolicyAccept, TransferPolicyReject:
+	default:
+		return fmt.Errorf("unknown transfer policy %q", h.Transfer.Policy)
+	}
+
+	if err := h.Exec.validate(); err != nil {
+		return err
+	}
+	if err := h.Docker.validate(); err != nil {
+		return err
+	}
+
+	for name, target := range h.Webhook.targets() {
+		if target == nil {
+			return fmt.Errorf("webhook target %s has no configuration", name)
+		}
+		if err := target.validate(); err != nil {
+			return fmt.Errorf("webhook target %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ResolveHosts returns the configuration of each virtual host with the default HostConfig
+// used for any setting the host does not declare. Hostnames are normalized.
+func (c *Config) ResolveHosts() (map[string]*HostConfig, error) {
+	defaults, err := json.Marshal(&c.HostConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]*HostConfig, len(c.Hosts))
+	for hostname, raw := range c.Hosts {
+		hostConfig := &HostConfig{}
+		// Round-trip the defaults so the host gets its own copy of any slices or maps
+		if err := json.Unmarshal(defaults, hostConfig); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, hostConfig); err != nil {
+			return nil, fmt.Errorf("invalid configuration for host %s: %w", hostname, err)
+		}
+
+		name := normalizeHostname(hostname)
+		if name == "" {
+			return nil, fmt.Errorf("invalid hostname %q", hostname)
+		}
+		resolved[name] = hostConfig
+	}
+	return resolved, nil
+}
+
+// normalizeHostname lower-cases the hostname and strips any port and trailing dot
+func normalizeHostname(hostname string) string {
+	hostname = strings.TrimSpace(hostname)
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = host
+	}
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
 }
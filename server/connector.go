@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -15,30 +18,51 @@ import (
 )
 
 const (
-	handshakeTimeout = 5 * time.Second
+	handshakeTimeout      = 5 * time.Second
+	backendConnectTimeout = 5 * time.Second
 )
 
 var noDeadline time.Time
 
-func NewConnector(ctx context.Context, config *Config, motdManager *MOTDManager) *Connector {
+func NewConnector(ctx context.Context, config *Config, hosts *hostRegistry, ipAccess *ipAccess) *Connector {
 
-	return &Connector{
-		ctx:         ctx,
-		config:      config,
-		motdManager: motdManager,
+	c := &Connector{
+		ctx:          ctx,
+		limiter:      newConnectionLimiter(ctx),
+		joinAttempts: &joinAttemptLog{},
 	}
+	c.swap(config, hosts, ipAccess)
+	return c
 }
 
 type Connector struct {
-	ctx                context.Context
-	config             *Config
-	motdManager        *MOTDManager
-	state              mcproto.State
-	connectionNotifier ConnectionNotifier
+	ctx      context.Context
+	config   atomic.Pointer[Config]
+	hosts    atomic.Pointer[hostRegistry]
+	ipAccess atomic.Pointer[ipAccess]
+	limiter  *connectionLimiter
+	state    mcproto.State
+	// joinAttempts keeps the recent join attempts listed by the admin API
+	joinAttempts *joinAttemptLog
+	// ready is set while the listener accepts connections
+	ready atomic.Bool
+	// trustedProxies is nil unless the PROXY protocol is enabled
+	trustedProxies atomic.Pointer[[]netip.Prefix]
 }
 
-func (c *Connector) UseConnectionNotifier(notifier ConnectionNotifier) {
-	c.connectionNotifier = notifier
+// swap atomically replaces the configuration used for new connections
+func (c *Connector) swap(config *Config, hosts *hostRegistry, ipAccess *ipAccess) {
+	c.config.Store(config)
+	c.hosts.Store(hosts)
+	c.ipAccess.Store(ipAccess)
+
+	if config.ProxyProtocol.Enabled {
+		// The configuration has been validated already
+		trustedProxies, _ := parsePrefixes(config.ProxyProtocol.TrustedProxies)
+		c.trustedProxies.Store(&trustedProxies)
+	} else {
+		c.trustedProxies.Store(nil)
+	}
 }
 
 func (c *Connector) StartAcceptingConnections(listenAddress string) error {
@@ -47,11 +71,17 @@ func (c *Connector) StartAcceptingConnections(listenAddress string) error {
 		return err
 	}
 
+	c.ready.Store(true)
 	go c.acceptConnections(ln)
 
 	return nil
 }
 
+// Ready reports whether the connector is accepting connections
+func (c *Connector) Ready() bool {
+	return c.ready.Load()
+}
+
 func (c *Connector) createListener(listenAddress string) (net.Listener, error) {
 	listener, err := net.Listen("tcp", listenAddress)
 	if err != nil {
@@ -71,6 +101,7 @@ func (c *Connector) AcceptConnection(conn net.Conn) {
 
 func (c *Connector) acceptConnections(ln net.Listener) {
 	defer ln.Close()
+	defer c.ready.Store(false)
 
 	for {
 		select {
@@ -81,13 +112,86 @@ func (c *Connector) acceptConnections(ln net.Listener) {
 			conn, err := ln.Accept()
 			if err != nil {
 				logrus.WithError(err).Error("Failed to accept connection")
-			} else {
-				go c.HandleConnection(conn)
+			} else if c.fromTrustedProxy(conn) {
+				// Counted in the global limits before the header is read, so that connections
+				// from proxies can't hold more goroutines than any others
+				if c.limiter.admitProxied(conn, &c.config.Load().RateLimit) {
+					go c.acceptProxiedConnection(conn)
+				}
+			} else if c.admit(conn) {
+				go c.handleAdmittedConnection(conn)
 			}
 		}
 	}
 }
 
+// fromTrustedProxy reports whether the connection is expected to start with a PROXY protocol header
+func (c *Connector) fromTrustedProxy(conn net.Conn) bool {
+	trustedProxies := c.trustedProxies.Load()
+	if trustedProxies == nil {
+		return false
+	}
+	ip, ok := clientIp(conn.RemoteAddr())
+	return ok && containsAddr(*trustedProxies, ip)
+}
+
+// acceptProxiedConnection reads the PROXY protocol header so that the address checks and
+// per address rate limit apply to the real client address. The connection has been admitted
+// by admitProxied already.
+func (c *Connector) acceptProxiedConnection(conn net.Conn) {
+	defer c.limiter.release()
+
+	proxyAddr := conn.RemoteAddr()
+	if err := conn.SetReadDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		logrus.WithError(err).WithField("proxy", proxyAddr).Error("Failed to set read deadline")
+		conn.Close()
+		return
+	}
+
+	proxied, err := readProxyHeader(conn)
+	if err != nil {
+		connectionsTotal.WithLabelValues(connectionOutcomeInvalidProxyHeader).Inc()
+		logrus.WithError(err).WithField("proxy", proxyAddr).Warn("Failed to read PROXY protocol header")
+		conn.Close()
+		return
+	}
+	logrus.
+		WithField("client", proxied.RemoteAddr()).
+		WithField("proxy", proxyAddr).
+		Debug("Got PROXY protocol header")
+
+	if c.blocksConnection(proxied) || !c.limiter.admitClient(proxied, &c.config.Load().RateLimit) {
+		return
+	}
+	connectionsTotal.WithLabelValues(connectionOutcomeHandled).Inc()
+	c.HandleConnection(proxied)
+}
+
+// admit applies the address checks and rate limits, closing the connection if it is refused
+func (c *Connector) admit(conn net.Conn) bool {
+	if c.blocksConnection(conn) || !c.limiter.admit(conn, &c.config.Load().RateLimit) {
+		return false
+	}
+	connectionsTotal.WithLabelValues(connectionOutcomeHandled).Inc()
+	return true
+}
+
+// blocksConnection closes the connection if its address is blocked before anything is read
+func (c *Connector) blocksConnection(conn net.Conn) bool {
+	if !c.ipAccess.Load().blocksConnection(conn.RemoteAddr()) {
+		return false
+	}
+	connectionsTotal.WithLabelValues(connectionOutcomeBlocked).Inc()
+	logrus.WithField("client", conn.RemoteAddr()).Debug("Closing connection from blocked address")
+	conn.Close()
+	return true
+}
+
+func (c *Connector) handleAdmittedConnection(conn net.Conn) {
+	defer c.limiter.release()
+	c.HandleConnection(conn)
+}
+
 func (c *Connector) HandleConnection(frontendConn net.Conn) {
 	defer frontendConn.Close()
 
@@ -113,6 +217,7 @@ func (c *Connector) HandleConnection(frontendConn net.Conn) {
 	}
 	packet, err := mcproto.ReadPacket(bufferedReader, clientAddr, c.state)
 	if err != nil {
+		countDecodeError("frame", err)
 		logrus.WithError(err).WithField("clientAddr", clientAddr).Error("Failed to read packet")
 		return
 	}
@@ -127,6 +232,7 @@ func (c *Connector) HandleConnection(frontendConn net.Conn) {
 	case mcproto.PacketIdHandshake:
 		handshake, err := mcproto.DecodeHandshake(packet.Data)
 		if err != nil {
+			decodeErrorsTotal.WithLabelValues("handshake").Inc()
 			logrus.WithError(err).WithField("clientAddr", clientAddr).
 				Error("Failed to read handshake")
 			return
@@ -138,16 +244,17 @@ func (c *Connector) HandleConnection(frontendConn net.Conn) {
 			Debug("Got handshake")
 
 		var playerInfo *PlayerInfo = nil
-		if handshake.NextState == mcproto.StateLogin {
+		if handshake.NextState == mcproto.StateLogin || handshake.NextState == mcproto.StateTransfer {
 			playerInfo, err = c.readPlayerInfo(handshake.ProtocolVersion, bufferedReader, clientAddr, handshake.NextState)
 			if err != nil {
-				if errors.Is(err, io.EOF) {
+				if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
 					logrus.
 						WithError(err).
 						WithField("clientAddr", clientAddr).
 						WithField("player", playerInfo).
 						Warn("Truncated buffer while reading player info")
 				} else {
+					decodeErrorsTotal.WithLabelValues("login_start").Inc()
 					logrus.
 						WithError(err).
 						WithField("clientAddr", clientAddr).
@@ -155,13 +262,33 @@ func (c *Connector) HandleConnection(frontendConn net.Conn) {
 					return
 				}
 			}
+			if playerInfo != nil {
+				playerInfo.Transferred = handshake.NextState == mcproto.StateTransfer
+			}
 			logrus.
 				WithField("client", clientAddr).
 				WithField("player", playerInfo).
 				Debug("Got user info")
+
+			if c.config.Load().Forwarding.Mode == ForwardingBungeeCord {
+				forwarded, err := mcproto.DecodeBungeeCordForwarding(handshake.AddressData)
+				if err != nil {
+					c.rejectUnforwarded(frontendConn, clientAddr, err)
+					return
+				}
+				clientAddr = forwardedClientAddr(forwarded)
+				if playerInfo != nil {
+					playerInfo.Uuid = forwarded.Uuid
+					playerInfo.Verified = true
+				}
+				logrus.
+					WithField("client", clientAddr).
+					WithField("player", playerInfo).
+					Debug("Got BungeeCord forwarded player")
+			}
 		}
 
-		c.findAndConnectBackend(frontendConn, clientAddr, inspectionBuffer, handshake.ServerAddress, playerInfo, handshake.NextState, bufferedReader)
+		c.findAndConnectBackend(frontendConn, clientAddr, inspectionBuffer, handshake, playerInfo, bufferedReader)
 
 	case mcproto.PacketIdLegacyServerListPing:
 		handshake, ok := packet.Data.(*mcproto.LegacyServerListPing)
@@ -178,10 +305,9 @@ func (c *Connector) HandleConnection(frontendConn net.Conn) {
 			WithField("handshake", handshake).
 			Debug("Got legacy server list ping")
 
-		serverAddress := handshake.ServerAddress
-
-		c.findAndConnectBackend(frontendConn, clientAddr, inspectionBuffer, serverAddress, nil, mcproto.StateStatus, bufferedReader)
+		c.handleLegacyStatusRequest(frontendConn, clientAddr, c.hosts.Load().lookup(handshake.ServerAddress), handshake)
 	default:
+		decodeErrorsTotal.WithLabelValues("handshake").Inc()
 		logrus.
 			WithField("client", clientAddr).
 			WithField("packetID", packet.PacketID).
@@ -211,20 +337,45 @@ func (c *Connector) readPlayerInfo(protocolVersion mcproto.ProtocolVersion, buff
 }
 
 func (c *Connector) findAndConnectBackend(frontendConn net.Conn,
-	clientAddr net.Addr, preReadContent io.Reader, serverAddress string, playerInfo *PlayerInfo, nextState mcproto.State, bufferedReader *bufio.Reader) {
+	clientAddr net.Addr, preReadContent io.Reader, handshake *mcproto.Handshake, playerInfo *PlayerInfo, bufferedReader *bufio.Reader) {
+
+	serverAddress := handshake.ServerAddress
+	nextState := handshake.NextState
+	host := c.hosts.Load().lookup(serverAddress)
+
+	if nextState == mcproto.StateStatus && isHealthcheck(serverAddress, clientAddr) {
+		c.handleHealthcheck(frontendConn, clientAddr, host, bufferedReader)
+		return
+	}
 
 	logrus.
 		WithField("client", clientAddr).
 		WithField("server", serverAddress).
+		WithField("host", host).
 		WithField("player", playerInfo).
 		WithField("nextState", nextState).
 		Info("Handling connection request")
 
+	if c.blocksRequest(frontendConn, clientAddr, serverAddress, host, playerInfo, nextState) {
+		return
+	}
+
+	if nextState == mcproto.StateTransfer && !host.config.Transfer.accepts() {
+		c.rejectTransfer(frontendConn, clientAddr, serverAddress, host, playerInfo)
+		return
+	}
+
+	if host.config.Backend.Passthrough && host.motdManager.GetCurrentState() == StateRunning {
+		if c.connectBackend(frontendConn, clientAddr, preReadContent, serverAddress, host, playerInfo) {
+			return
+		}
+	}
+
 	switch nextState {
 	case mcproto.StateStatus:
-		c.handleStatusRequest(frontendConn, clientAddr, serverAddress, bufferedReader)
-	case mcproto.StateLogin:
-		c.handleLoginRequest(frontendConn, clientAddr, serverAddress, playerInfo)
+		c.handleStatusRequest(frontendConn, clientAddr, serverAddress, host, bufferedReader)
+	case mcproto.StateLogin, mcproto.StateTransfer:
+		c.handleLoginRequest(frontendConn, clientAddr, handshake, host, playerInfo, bufferedReader)
 	default:
 		logrus.
 			WithField("client", clientAddr).
@@ -233,7 +384,130 @@ func (c *Connector) findAndConnectBackend(frontendConn net.Conn,
 	}
 }
 
-func (c *Connector) handleStatusRequest(frontendConn net.Conn, clientAddr net.Addr, serverAddress string, bufferedReader *bufio.Reader) {
+// blocksRequest turns away status and login requests from client addresses that are not allowed
+// to make them, reporting whether it did
+func (c *Connector) blocksRequest(frontendConn net.Conn, clientAddr net.Addr, serverAddress string,
+	host *virtualHost, playerInfo *PlayerInfo, nextState mcproto.State) bool {
+	access := c.ipAccess.Load()
+	switch nextState {
+	case mcproto.StateStatus:
+		if !access.blocks(IpBlockStatus, clientAddr) {
+			return false
+		}
+	case mcproto.StateLogin, mcproto.StateTransfer:
+		if !access.blocks(IpBlockLogin, clientAddr) {
+			return false
+		}
+		kickMessage := c.config.Load().Ip.KickMessage
+		if err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(kickMessage)); err != nil {
+			logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
+		}
+		c.notifyDenied(clientAddr, serverAddress, host, playerInfo, DeniedReasonAddress)
+	default:
+		return false
+	}
+
+	logrus.
+		WithField("client", clientAddr).
+		WithField("server", serverAddress).
+		WithField("nextState", nextState).
+		Info("Blocked request from address that is not allowed")
+	return true
+}
+
+// connectBackend proxies the connection to the backend server, replaying the content already
+// read from the client. It returns false if the backend could not be reached, in which case
+// nothing has been written to the client and the placeholder should answer instead.
+func (c *Connector) connectBackend(frontendConn net.Conn, clientAddr net.Addr, preReadContent io.Reader,
+	serverAddress string, host *virtualHost, playerInfo *PlayerInfo) bool {
+
+	backendHostPort := host.config.Backend.Address
+
+	logrus.
+		WithField("client", clientAddr).
+		WithField("server", serverAddress).
+		WithField("backendHostPort", backendHostPort).
+		WithField("player", playerInfo).
+		Info("Connecting to backend")
+
+	backendConn, err := net.DialTimeout("tcp", backendHostPort, backendConnectTimeout)
+	if err != nil {
+		logrus.
+			WithError(err).
+			WithField("client", clientAddr).
+			WithField("serverAddress", serverAddress).
+			WithField("backend", backendHostPort).
+			Warn("Unable to connect to backend, answering with placeholder")
+		return false
+	}
+	defer backendConn.Close()
+
+	// Clear the handshake deadline since the connection is now long-lived
+	if err := frontendConn.SetReadDeadline(noDeadline); err != nil {
+		logrus.
+			WithError(err).
+			WithField("client", clientAddr).
+			Error("Failed to clear read deadline")
+		return true
+	}
+
+	amount, err := io.Copy(backendConn, preReadContent)
+	if err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write handshake to backend connection")
+		return true
+	}
+	logrus.WithField("amount", amount).Debug("Relayed handshake to backend")
+
+	if host.notifier != nil {
+		if err := host.notifier.NotifyConnected(c.ctx, clientAddr, serverAddress, playerInfo, backendHostPort); err != nil {
+			logrus.WithError(err).Warn("failed to notify connected")
+		}
+		defer func() {
+			if err := host.notifier.NotifyDisconnected(c.ctx, clientAddr, serverAddress, playerInfo, backendHostPort); err != nil {
+				logrus.WithError(err).Warn("failed to notify disconnected")
+			}
+		}()
+	}
+
+	c.pumpConnections(frontendConn, backendConn)
+	return true
+}
+
+// pumpConnections copies data in both directions until either side closes its connection
+func (c *Connector) pumpConnections(frontendConn, backendConn net.Conn) {
+	clientAddr := frontendConn.RemoteAddr()
+	defer logrus.WithField("client", clientAddr).Debug("Closing backend connection")
+
+	errs := make(chan error, 2)
+	go c.pumpFrames(backendConn, frontendConn, errs, "backend", "frontend", clientAddr)
+	go c.pumpFrames(frontendConn, backendConn, errs, "frontend", "backend", clientAddr)
+
+	select {
+	case err := <-errs:
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+			logrus.WithError(err).WithField("client", clientAddr).Debug("Error observed on connection relay")
+		}
+	case <-c.ctx.Done():
+		logrus.WithField("client", clientAddr).Debug("Connector is stopping, closing relayed connection")
+	}
+}
+
+func (c *Connector) pumpFrames(incoming io.Reader, outgoing io.Writer, errs chan<- error, from, to string, clientAddr net.Addr) {
+	amount, err := io.Copy(outgoing, incoming)
+	logrus.
+		WithField("client", clientAddr).
+		WithField("amount", amount).
+		Debugf("Finished relay %s->%s", from, to)
+
+	if err != nil {
+		errs <- err
+	} else {
+		// successful io.Copy return nil error, not EOF...to simulate that to trigger outer handling
+		errs <- io.EOF
+	}
+}
+
+func (c *Connector) handleStatusRequest(frontendConn net.Conn, clientAddr net.Addr, serverAddress string, host *virtualHost, bufferedReader *bufio.Reader) {
 	logrus.
 		WithField("client", clientAddr).
 		WithField("server", serverAddress).
@@ -255,18 +529,15 @@ func (c *Connector) handleStatusRequest(frontendConn net.Conn, clientAddr net.Ad
 	}
 
 	if statusPacket.PacketID == mcproto.PacketIdStatusRequest {
-		currentMOTD := c.motdManager.GetCurrentMOTD()
-
-		err = mcproto.WriteStatusResponse(frontendConn,
-			currentMOTD,
-			c.config.ServerStatus.MaxPlayers,
-			0,
-			c.config.ServerStatus.Version,
-			c.config.ServerStatus.GetProtocol())
+		currentMOTD := host.motdManager.GetCurrentMOTD()
+
+		err = c.writeStatusResponse(frontendConn, host, currentMOTD)
 		if err != nil {
 			logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write status response")
 			return
 		}
+		statusRequestsTotal.WithLabelValues(host.String()).Inc()
+		c.notifyStatusRequest(clientAddr, serverAddress, host)
 
 		// Wait for ping request
 		pingPacket, err := mcproto.ReadPacket(bufferedReader, clientAddr, mcproto.StateStatus)
@@ -300,17 +571,187 @@ func (c *Connector) handleStatusRequest(frontendConn net.Conn, clientAddr net.Ad
 	}
 }
 
-func (c *Connector) handleLoginRequest(frontendConn net.Conn, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo) {
-	c.motdManager.OnJoinAttempt()
+// isHealthcheck reports whether the status request was sent by the healthcheck command of this machine
+func isHealthcheck(serverAddress string, clientAddr net.Addr) bool {
+	ip, ok := clientIp(clientAddr)
+	return serverAddress == HealthcheckServerAddress && ok && ip.IsLoopback()
+}
+
+// handleHealthcheck answers a health check status request. Unlike other status requests it is
+// not passed through to the backend, counted or notified.
+func (c *Connector) handleHealthcheck(frontendConn net.Conn, clientAddr net.Addr, host *virtualHost, bufferedReader *bufio.Reader) {
+	statusPacket, err := mcproto.ReadPacket(bufferedReader, clientAddr, mcproto.StateStatus)
+	if err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to read health check status packet")
+		return
+	}
+	if statusPacket.PacketID != mcproto.PacketIdStatusRequest {
+		return
+	}
+
+	if err := c.writeStatusResponse(frontendConn, host, host.motdManager.GetCurrentMOTD()); err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write health check status response")
+		return
+	}
+	logrus.WithField("client", clientAddr).Debug("Answered health check")
+}
+
+// writeStatusResponse answers with the mirrored status of the backend server if there is one,
+// otherwise with the configured server status
+func (c *Connector) writeStatusResponse(frontendConn net.Conn, host *virtualHost, motd string) error {
+	if host.statusMirror != nil {
+		status, err := host.statusMirror.render(mcproto.ParseText(motd), host.motdManager.GetCurrentFavicon())
+		if err != nil {
+			logrus.WithError(err).WithField("host", host).Warn("Unable to use status snapshot")
+		} else if status != nil {
+			return mcproto.WriteStatusResponseJSON(frontendConn, status)
+		}
+	}
+
+	return mcproto.WriteStatusResponse(frontendConn,
+		mcproto.ParseText(motd),
+		host.motdManager.GetCurrentFavicon(),
+		host.config.ServerStatus.MaxPlayers,
+		0,
+		host.config.ServerStatus.Version,
+		host.config.ServerStatus.GetProtocol())
+}
+
+func (c *Connector) handleLegacyStatusRequest(frontendConn net.Conn, clientAddr net.Addr, host *virtualHost, ping *mcproto.LegacyServerListPing) {
+	logrus.
+		WithField("client", clientAddr).
+		WithField("server", ping.ServerAddress).
+		WithField("host", host).
+		WithField("variant", ping.Variant).
+		Info("Handling legacy status request")
+
+	if c.ipAccess.Load().blocks(IpBlockStatus, clientAddr) {
+		logrus.
+			WithField("client", clientAddr).
+			WithField("server", ping.ServerAddress).
+			Info("Blocked legacy status request from address that is not allowed")
+		return
+	}
+
+	currentMOTD := host.motdManager.GetCurrentMOTD()
+
+	err := mcproto.WriteLegacyServerListPingResponse(frontendConn,
+		ping.Variant,
+		mcproto.ParseText(currentMOTD),
+		host.config.ServerStatus.MaxPlayers,
+		0,
+		host.config.ServerStatus.Version,
+		mcproto.LegacyPingProtocolVersion)
+	if err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write legacy status response")
+		return
+	}
+	legacyStatusRequestsTotal.WithLabelValues(host.String()).Inc()
+	c.notifyStatusRequest(clientAddr, ping.ServerAddress, host)
+
+	logrus.
+		WithField("client", clientAddr).
+		WithField("server", ping.ServerAddress).
+		WithField("motd", currentMOTD).
+		Info("Successfully handled legacy status request")
+}
+
+func (c *Connector) handleLoginRequest(frontendConn net.Conn, clientAddr net.Addr, handshake *mcproto.Handshake,
+	host *virtualHost, playerInfo *PlayerInfo, bufferedReader *bufio.Reader) {
+
+	serverAddress := handshake.ServerAddress
+	loginAttemptsTotal.WithLabelValues(host.String(), strconv.Itoa(int(handshake.ProtocolVersion))).Inc()
+
+	if forwarding := &c.config.Load().Forwarding; forwarding.Mode == ForwardingVelocity {
+		forwarded, err := c.receiveVelocityForwarding(frontendConn, clientAddr, bufferedReader, forwarding)
+		if err != nil {
+			c.rejectUnforwarded(frontendConn, clientAddr, err)
+			return
+		}
+		clientAddr = forwardedClientAddr(forwarded)
+		playerInfo = &PlayerInfo{
+			Name:        forwarded.Name,
+			Uuid:        forwarded.Uuid,
+			Transferred: playerInfo != nil && playerInfo.Transferred,
+			Verified:    true,
+		}
+		logrus.
+			WithField("client", clientAddr).
+			WithField("player", playerInfo).
+			Debug("Got Velocity forwarded player")
+	}
+
+	if c.ipAccess.Load().blocks(IpBlockWake, clientAddr) {
+		logrus.
+			WithField("client", clientAddr).
+			WithField("server", serverAddress).
+			WithField("host", host).
+			WithField("player", playerInfo).
+			Info("Address is not allowed to wake the server")
+		if err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(c.config.Load().Ip.KickMessage)); err != nil {
+			logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
+		}
+		c.notifyDenied(clientAddr, serverAddress, host, playerInfo, DeniedReasonAddress)
+		return
+	}
+
+	if host.config.Auth.OnlineMode {
+		authConn, authReader, verified, err := c.authenticatePlayer(frontendConn, clientAddr, handshake, host, playerInfo, bufferedReader)
+		if authConn != nil {
+			frontendConn, bufferedReader = authConn, authReader
+		}
+		if err != nil {
+			logrus.
+				WithError(err).
+				WithField("client", clientAddr).
+				WithField("server", serverAddress).
+				WithField("player", playerInfo).
+				Warn("Unable to verify player, ignoring join attempt")
+			if err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(host.config.Auth.KickMessage)); err != nil {
+				logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
+			}
+			// The name and UUID sent by the client are unverified, so they aren't reported
+			c.notifyDenied(clientAddr, serverAddress, host, nil, DeniedReasonAuth)
+			return
+		}
+		playerInfo = verified
+	}
+
+	if !host.allowed(playerInfo) {
+		logrus.
+			WithField("client", clientAddr).
+			WithField("server", serverAddress).
+			WithField("host", host).
+			WithField("player", playerInfo).
+			Info("Player is not allowed to wake the server")
+		if err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(host.config.Players.KickMessage)); err != nil {
+			logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
+		}
+		c.notifyDenied(clientAddr, serverAddress, host, playerInfo, DeniedReasonPlayer)
+		return
+	}
+
+	host.motdManager.OnJoinAttempt()
+	wakeAttemptsTotal.WithLabelValues(host.String()).Inc()
+	state := host.motdManager.GetCurrentState()
+	c.joinAttempts.record(clientAddr, handshake, host, playerInfo, state)
 
 	logrus.
 		WithField("client", clientAddr).
 		WithField("server", serverAddress).
+		WithField("host", host).
 		WithField("player", playerInfo).
-		Info("Handling login request - server is starting up")
+		WithField("state", state).
+		Info("Handling login request")
+
+	if host.config.Limbo.Enabled && playerInfo != nil && mcproto.PlayStateSupported(handshake.ProtocolVersion) {
+		c.notifyJoinAttempt(clientAddr, serverAddress, host, playerInfo)
+		c.holdInLimbo(frontendConn, clientAddr, handshake, host, playerInfo, bufferedReader)
+		return
+	}
 
-	disconnectReason := "🚀 Server is waking up! Please try again in a few minutes."
-	err := mcproto.WriteDisconnect(frontendConn, disconnectReason)
+	disconnectReason := host.motdManager.GetCurrentKickMessage()
+	err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(disconnectReason))
 	if err != nil {
 		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
 		return
@@ -321,13 +762,67 @@ func (c *Connector) handleLoginRequest(frontendConn net.Conn, clientAddr net.Add
 		WithField("server", serverAddress).
 		WithField("player", playerInfo).
 		WithField("reason", disconnectReason).
-		Info("Disconnected player with startup message")
+		Info("Disconnected player with state message")
 
-	if c.connectionNotifier != nil {
+	c.notifyJoinAttempt(clientAddr, serverAddress, host, playerInfo)
+}
+
+// rejectUnforwarded disconnects a player whose details were not forwarded by the proxy in front
+func (c *Connector) rejectUnforwarded(frontendConn net.Conn, clientAddr net.Addr, err error) {
+	logrus.
+		WithError(err).
+		WithField("client", clientAddr).
+		Warn("Player details were not forwarded by the proxy")
+	kickMessage := c.config.Load().Forwarding.KickMessage
+	if err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(kickMessage)); err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
+	}
+}
+
+// rejectTransfer disconnects a player that arrived through a Transfer packet without
+// counting it as a join attempt
+func (c *Connector) rejectTransfer(frontendConn net.Conn, clientAddr net.Addr, serverAddress string, host *virtualHost, playerInfo *PlayerInfo) {
+	reason := host.config.Transfer.KickMessage
+	if err := mcproto.WriteDisconnect(frontendConn, mcproto.ParseText(reason)); err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Failed to write disconnect packet")
+		return
+	}
+
+	logrus.
+		WithField("client", clientAddr).
+		WithField("server", serverAddress).
+		WithField("host", host).
+		WithField("player", playerInfo).
+		Info("Rejected transferred player")
+	c.notifyDenied(clientAddr, serverAddress, host, playerInfo, DeniedReasonTransfer)
+}
+
+// notifyJoinAttempt lets the host's notifier know that a player tried to join while the
+// backend was not available, which is what wakes it up
+func (c *Connector) notifyJoinAttempt(clientAddr net.Addr, serverAddress string, host *virtualHost, playerInfo *PlayerInfo) {
+	if host.notifier != nil {
 		backendErr := fmt.Errorf("server is starting up, no backend available")
-		notifyErr := c.connectionNotifier.NotifyFailedBackendConnection(c.ctx, clientAddr, serverAddress, playerInfo, serverAddress, backendErr)
+		notifyErr := host.notifier.NotifyFailedBackendConnection(c.ctx, clientAddr, serverAddress, playerInfo, serverAddress, backendErr)
 		if notifyErr != nil {
 			logrus.WithError(notifyErr).Warn("failed to notify failed backend connection")
 		}
 	}
 }
+
+// notifyStatusRequest lets the host's notifier know that a status request was answered
+func (c *Connector) notifyStatusRequest(clientAddr net.Addr, serverAddress string, host *virtualHost) {
+	if host.notifier != nil {
+		if err := host.notifier.NotifyStatusRequest(c.ctx, clientAddr, serverAddress); err != nil {
+			logrus.WithError(err).Warn("failed to notify status request")
+		}
+	}
+}
+
+// notifyDenied lets the host's notifier know that a player was not allowed to wake the server
+func (c *Connector) notifyDenied(clientAddr net.Addr, serverAddress string, host *virtualHost, playerInfo *PlayerInfo, reason string) {
+	if host.notifier != nil {
+		if err := host.notifier.NotifyDenied(c.ctx, clientAddr, serverAddress, playerInfo, reason); err != nil {
+			logrus.WithError(err).Warn("failed to notify denied player")
+		}
+	}
+}
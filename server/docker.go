@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errDockerContainerNotFound is returned when the Docker Engine has no container of the name
+var errDockerContainerNotFound = errors.New("container not found")
+
+// dockerClient talks to the Docker Engine API over its unix socket
+type dockerClient struct {
+	socket string
+	client *http.Client
+}
+
+// newDockerClient creates a client for the socket, which may be given as a unix:// URL like DOCKER_HOST
+func newDockerClient(socket string, timeout time.Duration) *dockerClient {
+	socket = strings.TrimPrefix(socket, "unix://")
+	return &dockerClient{
+		socket: socket,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// dockerContainer holds the fields of a container inspection used to determine the server state
+type dockerContainer struct {
+	State struct {
+		Status    string
+		OOMKilled bool
+		ExitCode  int
+		Health    *struct {
+			Status string
+		}
+	}
+}
+
+func (d *dockerClient) do(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, "http://docker"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		_ = resp.Body.Close()
+		return nil, errDockerContainerNotFound
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var message struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&message)
+		return nil, fmt.Errorf("docker engine responded with status %d: %s", resp.StatusCode, message.Message)
+	}
+	return resp, nil
+}
+
+func (d *dockerClient) inspect(ctx context.Context, container string) (*dockerContainer, error) {
+	resp, err := d.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(container)+"/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result := &dockerContainer{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("invalid container inspection: %w", err)
+	}
+	return result, nil
+}
+
+// start starts the container, reporting whether it was stopped
+func (d *dockerClient) start(ctx context.Context, container string) (bool, error) {
+	resp, err := d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/start")
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+	// The engine responds with 304 Not Modified if the container is already running
+	return resp.StatusCode != http.StatusNotModified, nil
+}
+
+// unpause resumes the processes of a paused container
+func (d *dockerClient) unpause(ctx context.Context, container string) error {
+	resp, err := d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(container)+"/unpause")
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// wake starts the container or, since a paused container can't be started, unpauses it,
+// reporting whether it wasn't running
+func (d *dockerClient) wake(ctx context.Context, container string) (bool, error) {
+	inspection, err := d.inspect(ctx, container)
+	if err != nil {
+		return false, err
+	}
+	if inspection.State.Status == "paused" {
+		return true, d.unpause(ctx, container)
+	}
+	return d.start(ctx, container)
+}
+
+// dockerStateProbe determines the server state from the status and health of its container
+type dockerStateProbe struct {
+	client    *dockerClient
+	container string
+}
+
+func newDockerStateProbe(client *dockerClient, container string) *dockerStateProbe {
+	return &dockerStateProbe{client: client, container: container}
+}
+
+func (p *dockerStateProbe) probe(ctx context.Context) (ServerState, bool, error) {
+	container, err := p.client.inspect(ctx, p.container)
+	if err != nil {
+		return "", false, err
+	}
+	state, known := dockerContainerState(container)
+	return state, known, nil
+}
+
+// dockerContainerState maps the status of a container to the server state. A running container
+// with a health check is starting until it is healthy.
+func dockerContainerState(container *dockerContainer) (ServerState, bool) {
+	switch container.State.Status {
+	case "running":
+		if container.State.Health == nil {
+			return StateRunning, true
+		}
+		switch container.State.Health.Status {
+		case "healthy":
+			return StateRunning, true
+		case "unhealthy":
+			return StateCrashed, true
+		default:
+			return StateStarting, true
+		}
+	case "restarting":
+		return StateStarting, true
+	case "removing":
+		return StateStopping, true
+	case "created", "paused":
+		return StateSleeping, true
+	case "exited":
+		if container.State.OOMKilled {
+			return StateCrashed, true
+		}
+		return StateSleeping, true
+	case "dead":
+		return StateCrashed, true
+	default:
+		return "", false
+	}
+}
+
+func (p *dockerStateProbe) String() string {
+	return "docker:" + p.container
+}
+
+// DockerNotifier implements ConnectionNotifier by starting or unpausing a Docker container for
+// the selected events. Events arriving while a start is in progress are skipped.
+type DockerNotifier struct {
+	*payloadNotifier
+	client    *dockerClient
+	container string
+
+	// starting is shared by the Docker notifiers of the host, see notifierGuards
+	starting *atomic.Bool
+}
+
+// NewDockerNotifier creates a notifier starting the container, unless starting is set by a start
+// already in progress for the host
+func NewDockerNotifier(host string, config *DockerConfig, motdManager *MOTDManager, starting *atomic.Bool) *DockerNotifier {
+	d := &DockerNotifier{
+		client:    newDockerClient(config.Socket, time.Duration(config.Timeout)*time.Second),
+		container: config.Container,
+		starting:  starting,
+	}
+	d.payloadNotifier = newPayloadNotifier(host, config.Events, motdManager, d.start)
+	return d
+}
+
+// start starts the container in the background unless a start is already in progress
+func (d *DockerNotifier) start(ctx context.Context, payload *WebhookNotifierPayload) error {
+	if !d.starting.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer d.starting.Store(false)
+
+		started, err := d.client.wake(ctx, d.container)
+		if err != nil {
+			logrus.
+				WithError(err).
+				WithField("host", d.host).
+				WithField("container", d.container).
+				WithField("event", payload.Event).
+				Warn("Unable to start container")
+			return
+		}
+		if started {
+			logrus.
+				WithField("host", d.host).
+				WithField("container", d.container).
+				WithField("event", payload.Event).
+				WithField("player", payload.PlayerInfo).
+				Info("Started container")
+		}
+	}()
+	return nil
+}
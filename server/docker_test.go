@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDockerEngine serves the parts of the Docker Engine API used by dockerClient on a unix socket
+type fakeDockerEngine struct {
+	mu         sync.Mutex
+	containers map[string]string
+	requests   []string
+}
+
+func newFakeDockerEngine(t *testing.T, containers map[string]string) (*fakeDockerEngine, *dockerClient) {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socket, err)
+	}
+
+	engine := &fakeDockerEngine{containers: containers}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /containers/{name}/json", engine.inspect)
+	mux.HandleFunc("POST /containers/{name}/{action}", engine.action)
+
+	server := &http.Server{Handler: mux}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+
+	return engine, newDockerClient("unix://"+socket, time.Second)
+}
+
+func (e *fakeDockerEngine) inspect(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status, exists := e.containers[r.PathValue("name")]
+	if !exists {
+		http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+		return
+	}
+	var container dockerContainer
+	container.State.Status = status
+	_ = json.NewEncoder(w).Encode(container)
+}
+
+func (e *fakeDockerEngine) action(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	name, action := r.PathValue("name"), r.PathValue("action")
+	e.requests = append(e.requests, action+" "+name)
+
+	status, exists := e.containers[name]
+	if !exists {
+		http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+		return
+	}
+	switch {
+	case action == "start" && status == "running":
+		w.WriteHeader(http.StatusNotModified)
+	case action == "start" && status == "paused":
+		http.Error(w, `{"message":"cannot start a paused container, try unpause instead"}`, http.StatusConflict)
+	case action == "start", action == "unpause" && status == "paused":
+		e.containers[name] = "running"
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"message":"unexpected request"}`, http.StatusConflict)
+	}
+}
+
+func TestDockerContainerState(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		health    string
+		oomKilled bool
+		want      ServerState
+	}{
+		{name: "running", status: "running", want: StateRunning},
+		{name: "healthy", status: "running", health: "healthy", want: StateRunning},
+		{name: "health starting", status: "running", health: "starting", want: StateStarting},
+		{name: "unhealthy", status: "running", health: "unhealthy", want: StateCrashed},
+		{name: "paused", status: "paused", want: StateSleeping},
+		{name: "exited", status: "exited", want: StateSleeping},
+		{name: "oom killed", status: "exited", oomKilled: true, want: StateCrashed},
+		{name: "dead", status: "dead", want: StateCrashed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var container dockerContainer
+			container.State.Status = test.status
+			container.State.OOMKilled = test.oomKilled
+			if test.health != "" {
+				container.State.Health = &struct{ Status string }{Status: test.health}
+			}
+
+			state, known := dockerContainerState(&container)
+			if !known || state != test.want {
+				t.Errorf("got %q (known %v), want %q", state, known, test.want)
+			}
+		})
+	}
+
+	if _, known := dockerContainerState(&dockerContainer{}); known {
+		t.Error("unknown container status was mapped to a state")
+	}
+}
+
+func TestDockerClientStart(t *testing.T) {
+	_, client := newFakeDockerEngine(t, map[string]string{"mc": "exited"})
+	ctx := context.Background()
+
+	// 204 No Content
+	started, err := client.start(ctx, "mc")
+	if err != nil || !started {
+		t.Fatalf("starting a stopped container: started %v, error %v", started, err)
+	}
+
+	// 304 Not Modified
+	started, err = client.start(ctx, "mc")
+	if err != nil || started {
+		t.Fatalf("starting a running container: started %v, error %v", started, err)
+	}
+
+	// 404 Not Found
+	if _, err := client.start(ctx, "missing"); !errors.Is(err, errDockerContainerNotFound) {
+		t.Fatalf("starting a missing container: got error %v", err)
+	}
+}
+
+func TestDockerClientWakeUnpauses(t *testing.T) {
+	engine, client := newFakeDockerEngine(t, map[string]string{"mc": "paused"})
+
+	woken, err := client.wake(context.Background(), "mc")
+	if err != nil || !woken {
+		t.Fatalf("waking a paused container: woken %v, error %v", woken, err)
+	}
+	if len(engine.requests) != 1 || engine.requests[0] != "unpause mc" {
+		t.Errorf("got requests %v, want [unpause mc]", engine.requests)
+	}
+
+	state, _, err := newDockerStateProbe(client, "mc").probe(context.Background())
+	if err != nil || state != StateRunning {
+		t.Errorf("got state %q, error %v after unpausing", state, err)
+	}
+}
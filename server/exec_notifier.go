@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// execWaitDelay bounds the wait for the output of a command after it timed out
+const execWaitDelay = 5 * time.Second
+
+// ExecNotifier implements ConnectionNotifier by running a shell command for the selected events.
+// Only one command runs at a time and events arriving meanwhile are skipped, since the running
+// command is already starting the server.
+type ExecNotifier struct {
+	*payloadNotifier
+	command      string
+	timeout      time.Duration
+	failureState ServerState
+
+	// running is shared by the exec notifiers of the host, see notifierGuards
+	running *atomic.Bool
+}
+
+// NewExecNotifier creates a notifier running the command, unless running is set by a command
+// already running for the host
+func NewExecNotifier(host string, config *ExecConfig, motdManager *MOTDManager, running *atomic.Bool) (*ExecNotifier, error) {
+	var failureState ServerState
+	if config.FailureState != "" {
+		state, err := ParseServerState(config.FailureState)
+		if err != nil {
+			return nil, err
+		}
+		failureState = state
+	}
+
+	e := &ExecNotifier{
+		command:      config.Command,
+		timeout:      time.Duration(config.Timeout) * time.Second,
+		failureState: failureState,
+		running:      running,
+	}
+	e.payloadNotifier = newPayloadNotifier(host, config.Events, motdManager, e.run)
+	return e, nil
+}
+
+// run starts the command in the background unless one is already running
+func (e *ExecNotifier) run(ctx context.Context, payload *WebhookNotifierPayload) error {
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	if !e.running.CompareAndSwap(false, true) {
+		logrus.
+			WithField("host", e.host).
+			WithField("event", payload.Event).
+			Debug("Command is already running, skipping event")
+		return nil
+	}
+
+	// The command keeps running when the host is replaced by a reload or the server stops
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer e.running.Store(false)
+		e.execute(ctx, payload, input)
+	}()
+	return nil
+}
+
+func (e *ExecNotifier) execute(ctx context.Context, payload *WebhookNotifierPayload, input []byte) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", e.command)
+	cmd.Env = append(os.Environ(), execEnv(payload)...)
+	cmd.Stdin = bytes.NewReader(input)
+	// Don't wait for children of a killed shell that keep its output open
+	cmd.WaitDelay = execWaitDelay
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	logger := logrus.
+		WithField("host", e.host).
+		WithField("event", payload.Event).
+		WithField("command", e.command).
+		WithField("exitCode", exitCode).
+		WithField("duration", time.Since(start).Round(time.Millisecond)).
+		WithField("output", strings.TrimSpace(string(output)))
+
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("timed out after %s: %w", e.timeout, err)
+		}
+		logger.WithError(err).Warn("Command failed")
+		if e.failureState != "" {
+			e.motdManager.SetFailedState(e.failureState)
+		}
+		return
+	}
+
+	logger.Info("Ran command")
+	if e.failureState != "" {
+		e.motdManager.ClearFailedState()
+	}
+}
+
+// execEnv returns the environment variables describing the event
+func execEnv(payload *WebhookNotifierPayload) []string {
+	var player, playerUuid, clientIp string
+	if payload.PlayerInfo != nil {
+		player = payload.PlayerInfo.Name
+		playerUuid = payload.PlayerInfo.Uuid.String()
+	}
+	if payload.Client != nil {
+		clientIp = payload.Client.Host
+	}
+
+	return []string{
+		"MC_MOTD_EVENT_ID=" + payload.Id,
+		"MC_MOTD_EVENT=" + payload.Event,
+		"MC_MOTD_STATUS=" + payload.Status,
+		"MC_MOTD_HOST=" + payload.Host,
+		"MC_MOTD_SERVER=" + payload.Server,
+		"MC_MOTD_STATE=" + string(payload.State),
+		"MC_MOTD_PREVIOUS_STATE=" + string(payload.PreviousState),
+		"MC_MOTD_PLAYER=" + player,
+		"MC_MOTD_PLAYER_UUID=" + playerUuid,
+		"MC_MOTD_CLIENT_IP=" + clientIp,
+		"MC_MOTD_REASON=" + payload.Reason,
+	}
+}
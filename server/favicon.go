@@ -0,0 +1,32 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"os"
+)
+
+const faviconSize = 64
+
+// LoadFavicon reads the PNG file at the given path, verifies that it is a 64x64 image as required
+// by the Minecraft client and returns it encoded as a data URI suitable for the status response.
+func LoadFavicon(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read favicon: %w", err)
+	}
+
+	imgConfig, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("favicon %s is not a valid PNG: %w", path, err)
+	}
+
+	if imgConfig.Width != faviconSize || imgConfig.Height != faviconSize {
+		return "", fmt.Errorf("favicon %s must be %dx%d, got %dx%d",
+			path, faviconSize, faviconSize, imgConfig.Width, imgConfig.Height)
+	}
+
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
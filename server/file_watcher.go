@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// watchedFile notices changes of a file by its modification time and size
+type watchedFile struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// newWatchedFile records the current version of the file, so that changed reports later changes
+func newWatchedFile(path string) *watchedFile {
+	f := &watchedFile{path: path}
+	f.modTime, f.size = f.stat()
+	return f
+}
+
+// stat returns the modification time and size of the file, or a size of -1 if it doesn't exist
+func (f *watchedFile) stat() (time.Time, int64) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return time.Time{}, -1
+	}
+	return info.ModTime(), info.Size()
+}
+
+// changed reports whether the file changed since the version last recorded and records the
+// current one. The file should be read after the check, so that a change made meanwhile is
+// noticed by the next one.
+func (f *watchedFile) changed() bool {
+	modTime, size := f.stat()
+	if modTime.Equal(f.modTime) && size == f.size {
+		return false
+	}
+	f.modTime, f.size = modTime, size
+	return true
+}
+
+// decode reads the JSON list in the file into entries
+func (f *watchedFile) decode(entries any) error {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, entries); err != nil {
+		return fmt.Errorf("invalid list file %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// watchFiles polls the files at the given interval and calls changed when any of them changed.
+// It returns when the context is done.
+func watchFiles(ctx context.Context, interval time.Duration, changed func(), files ...*watchedFile) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modified := false
+		for _, file := range files {
+			// Every file is checked, so that each records its current version
+			if file.changed() {
+				modified = true
+			}
+		}
+		if modified {
+			changed()
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/wroud/mc-motd/mcproto"
+)
+
+// Modes of forwarding player details from a proxy in front
+const (
+	ForwardingBungeeCord = "bungeecord"
+	ForwardingVelocity   = "velocity"
+)
+
+// forwardedClientAddr returns the address of the client as forwarded by the proxy. Proxies don't
+// forward the client's port.
+func forwardedClientAddr(forwarded *mcproto.ForwardedPlayer) net.Addr {
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(forwarded.Address, 0))
+}
+
+// receiveVelocityForwarding asks Velocity for the player details with a login plugin request
+// and verifies the answer with the forwarding secret
+func (c *Connector) receiveVelocityForwarding(frontendConn net.Conn, clientAddr net.Addr,
+	bufferedReader *bufio.Reader, forwarding *ForwardingConfig) (*mcproto.ForwardedPlayer, error) {
+
+	secret, err := forwarding.secret()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read forwarding secret: %w", err)
+	}
+
+	messageId := rand.IntN(1 << 30)
+	err = mcproto.WriteLoginPluginRequest(frontendConn, messageId, mcproto.VelocityForwardingChannel,
+		[]byte{mcproto.VelocityForwardingVersion})
+	if err != nil {
+		return nil, fmt.Errorf("failed to write login plugin request: %w", err)
+	}
+
+	if err := frontendConn.SetReadDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return nil, err
+	}
+	defer frontendConn.SetReadDeadline(noDeadline)
+
+	packet, err := mcproto.ReadPacket(bufferedReader, clientAddr, mcproto.StateLogin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read login plugin response: %w", err)
+	}
+	if packet.PacketID != mcproto.PacketIdLoginPluginResponse {
+		return nil, fmt.Errorf("expected login plugin response, got %d", packet.PacketID)
+	}
+	response, err := mcproto.DecodeLoginPluginResponse(packet.Data)
+	if err != nil {
+		return nil, err
+	}
+	if response.MessageId != messageId {
+		return nil, fmt.Errorf("unexpected login plugin message id %d", response.MessageId)
+	}
+	if !response.Successful {
+		return nil, fmt.Errorf("the proxy did not forward player details, is modern forwarding enabled in Velocity?")
+	}
+
+	return mcproto.DecodeVelocityForwarding(response.Data, secret)
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// virtualHost holds the state kept independently for each hostname served, so that a join
+// attempt for one host does not change the MOTD of any other.
+type virtualHost struct {
+	name        string
+	config      *HostConfig
+	motdManager *MOTDManager
+	notifier    ConnectionNotifier
+	// statusMirror is nil unless a status snapshot is configured
+	statusMirror *statusMirror
+	// playerAccess is nil unless an allowlist or denylist is configured
+	playerAccess *playerAccess
+	// stateWatcher is nil unless there is a notifier
+	stateWatcher *stateWatcher
+}
+
+func newVirtualHost(name string, config *HostConfig, webhookQueue *WebhookQueue, guards *notifierGuards) (*virtualHost, error) {
+	stateProvider, err := NewStateProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	motdManager, err := NewMOTDManager(&config.ServerStatus, stateProvider)
+	if err != nil {
+		if stateProvider != nil {
+			stateProvider.Close()
+		}
+		return nil, err
+	}
+
+	playerAccess, err := newPlayerAccess(&config.Players)
+	if err != nil {
+		motdManager.Close()
+		return nil, err
+	}
+
+	host := &virtualHost{
+		name:         name,
+		config:       config,
+		motdManager:  motdManager,
+		playerAccess: playerAccess,
+	}
+
+	if stateProvider != nil {
+		logrus.WithField("type", config.stateProviderType()).
+			WithField("host", host).
+			Info("Using state provider to determine the server state")
+	}
+
+	if config.Backend.StatusSnapshot != "" {
+		logrus.WithField("backend", config.Backend.Address).
+			WithField("file", config.Backend.StatusSnapshot).
+			WithField("host", host).
+			Info("Mirroring the status of the backend server")
+		host.statusMirror = newStatusMirror(config.Backend.Address, config.Backend.StatusSnapshot,
+			time.Duration(config.Backend.SnapshotInterval)*time.Second)
+	}
+
+	targets := config.Webhook.targets()
+	var notifiers CompositeNotifier
+	for _, name := range slices.Sorted(maps.Keys(targets)) {
+		target := targets[name]
+		notifier, err := NewWebhookNotifier(host.String(), name, target, motdManager, webhookQueue)
+		if err != nil {
+			host.close()
+			return nil, fmt.Errorf("webhook target %s: %w", name, err)
+		}
+		logrus.WithField("target", name).
+			WithField("url", target.Url).
+			WithField("events", notifier.events).
+			WithField("signed", notifier.signed).
+			WithField("host", host).
+			Info("Using webhook for connection status notifications")
+		notifiers = append(notifiers, notifier)
+	}
+	if config.Exec.Command != "" {
+		notifier, err := NewExecNotifier(host.String(), &config.Exec, motdManager, guards.get("exec", host.String()))
+		if err != nil {
+			host.close()
+			return nil, fmt.Errorf("exec: %w", err)
+		}
+		logrus.WithField("command", config.Exec.Command).
+			WithField("events", notifier.events).
+			WithField("host", host).
+			Info("Running command on server events")
+		notifiers = append(notifiers, notifier)
+	}
+	if config.Docker.Container != "" {
+		notifier := NewDockerNotifier(host.String(), &config.Docker, motdManager, guards.get("docker", host.String()))
+		logrus.WithField("container", config.Docker.Container).
+			WithField("socket", config.Docker.Socket).
+			WithField("events", notifier.events).
+			WithField("host", host).
+			Info("Starting Docker container on server events")
+		notifiers = append(notifiers, notifier)
+	}
+	if len(notifiers) > 0 {
+		host.notifier = notifiers
+		host.stateWatcher = newStateWatcher(host, host.notifier)
+	}
+
+	return host, nil
+}
+
+func (h *virtualHost) String() string {
+	if h.name == "" {
+		return "default"
+	}
+	return h.name
+}
+
+func (h *virtualHost) close() {
+	if h.stateWatcher != nil {
+		h.stateWatcher.close()
+	}
+	h.motdManager.Close()
+	if h.statusMirror != nil {
+		h.statusMirror.close()
+	}
+	if h.playerAccess != nil {
+		h.playerAccess.close()
+	}
+}
+
+// allowed reports whether the player may wake the server of this host
+func (h *virtualHost) allowed(player *PlayerInfo) bool {
+	return h.playerAccess == nil || h.playerAccess.allowed(player)
+}
+
+// hostRegistry resolves the virtual host for the server address given by a client
+type hostRegistry struct {
+	defaultHost *virtualHost
+	hosts       map[string]*virtualHost
+}
+
+func newHostRegistry(config *Config, webhookQueue *WebhookQueue, guards *notifierGuards) (*hostRegistry, error) {
+	hostConfigs, err := config.ResolveHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	defaultHost, err := newVirtualHost("", &config.HostConfig, webhookQueue, guards)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := &hostRegistry{
+		defaultHost: defaultHost,
+		hosts:       make(map[string]*virtualHost, len(hostConfigs)),
+	}
+	for name, hostConfig := range hostConfigs {
+		host, err := newVirtualHost(name, hostConfig, webhookQueue, guards)
+		if err != nil {
+			registry.close()
+			return nil, err
+		}
+		registry.hosts[name] = host
+		logrus.WithField("host", name).Info("Configured virtual host")
+	}
+
+	return registry, nil
+}
+
+// lookup returns the host configured for the exact server address, then for a wildcard
+// matching any of its parent domains, and otherwise the default host.
+func (r *hostRegistry) lookup(serverAddress string) *virtualHost {
+	name := normalizeHostname(serverAddress)
+	if host, exists := r.hosts[name]; exists {
+		return host
+	}
+
+	for {
+		_, parent, found := strings.Cut(name, ".")
+		if !found {
+			break
+		}
+		if host, exists := r.hosts["*."+parent]; exists {
+			return host
+		}
+		name = parent
+	}
+
+	return r.defaultHost
+}
+
+// get returns the host configured with exactly the given name, where "default" is the default host
+func (r *hostRegistry) get(name string) *virtualHost {
+	if name == r.defaultHost.String() {
+		return r.defaultHost
+	}
+	return r.hosts[name]
+}
+
+// all returns the default host followed by the virtual hosts sorted by name
+func (r *hostRegistry) all() []*virtualHost {
+	hosts := []*virtualHost{r.defaultHost}
+	for _, name := range slices.Sorted(maps.Keys(r.hosts)) {
+		hosts = append(hosts, r.hosts[name])
+	}
+	return hosts
+}
+
+// inheritState carries over the state of hosts that are also present in the previous registry
+func (r *hostRegistry) inheritState(previous *hostRegistry) {
+	r.defaultHost.motdManager.inheritState(previous.defaultHost.motdManager)
+	for name, host := range r.hosts {
+		if previousHost, exists := previous.hosts[name]; exists {
+			host.motdManager.inheritState(previousHost.motdManager)
+		}
+	}
+}
+
+func (r *hostRegistry) close() {
+	r.defaultHost.close()
+	for _, host := range r.hosts {
+		host.close()
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const httpShutdownTimeout = 5 * time.Second
+
+// newHttpHandler serves the HTTP endpoints of the server
+func (s *Server) newHttpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.HandlerFor(newMetricsRegistry(s.connector), promhttp.HandlerOpts{}))
+	(&adminApi{connector: s.connector}).register(mux)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	return mux
+}
+
+// handleReady reports ready once the Minecraft listener accepts connections
+func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
+	if !s.connector.Ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// startHttpServer listens on the address and serves the HTTP endpoints until the context is done
+func (s *Server) startHttpServer(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	logrus.WithField("address", address).Info("Serving HTTP endpoints")
+
+	httpServer := &http.Server{
+		Handler:           s.newHttpHandler(),
+		ReadHeaderTimeout: handshakeTimeout,
+	}
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Error("HTTP server failed")
+		}
+	}()
+	go func() {
+		<-s.ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		_ = httpServer.Shutdown(ctx)
+	}()
+	return nil
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Actions that can be blocked for client addresses that are not allowed
+const (
+	IpBlockStatus = "status"
+	IpBlockLogin  = "login"
+	IpBlockWake   = "wake"
+)
+
+// ipListEntry is an entry of the vanilla banned-ips.json file
+type ipListEntry struct {
+	Ip      string `json:"ip"`
+	Expires string `json:"expires,omitempty"`
+}
+
+// parsePrefix parses a CIDR or a single address
+func parsePrefix(entry string) (netip.Prefix, error) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address or CIDR %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIp returns the IP address of a client connection, if it has one
+func clientIp(addr net.Addr) (netip.Addr, bool) {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		ip, ok := netip.AddrFromSlice(tcpAddr.IP)
+		return ip.Unmap(), ok
+	}
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addrPort.Addr().Unmap(), true
+}
+
+// ipAccess decides which client addresses may connect from CIDR allow and deny lists and the
+// vanilla banned-ips.json file
+type ipAccess struct {
+	allow    []netip.Prefix
+	denyList []netip.Prefix
+	denyFile *watchedFile
+	blocked  map[string]bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	// deny holds the deny list together with the addresses banned in the file
+	deny atomic.Pointer[[]netip.Prefix]
+}
+
+// newIpAccess loads the configured address lists or returns nil if there are none
+func newIpAccess(config *IpConfig) (*ipAccess, error) {
+	if !config.enabled() {
+		return nil, nil
+	}
+
+	allow, err := parsePrefixes(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+	denyList, err := parsePrefixes(config.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &ipAccess{
+		allow:    allow,
+		denyList: denyList,
+		blocked:  make(map[string]bool, len(config.Block)),
+		done:     make(chan struct{}),
+	}
+	for _, action := range config.Block {
+		a.blocked[strings.ToLower(action)] = true
+	}
+	if config.DenyFile != "" {
+		a.denyFile = newWatchedFile(config.DenyFile)
+	}
+
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, a.cancel = context.WithCancel(context.Background())
+	go a.watch(ctx)
+	return a, nil
+}
+
+func (a *ipAccess) load() error {
+	deny := append([]netip.Prefix(nil), a.denyList...)
+	if a.denyFile != nil {
+		var entries []ipListEntry
+		if err := a.denyFile.decode(&entries); err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, entry := range entries {
+			if banExpired(entry.Expires, now) {
+				continue
+			}
+			prefix, err := parsePrefix(entry.Ip)
+			if err != nil {
+				logrus.WithField("ip", entry.Ip).
+					WithField("file", a.denyFile.path).
+					Warn("Ignoring invalid banned address")
+				continue
+			}
+			deny = append(deny, prefix)
+		}
+	}
+	a.deny.Store(&deny)
+	return nil
+}
+
+// watch reloads the banned addresses when the file changes, keeping the previous ones if the
+// file can't be read
+func (a *ipAccess) watch(ctx context.Context) {
+	defer close(a.done)
+	if a.denyFile == nil {
+		return
+	}
+
+	watchFiles(ctx, playerListReloadInterval, func() {
+		if err := a.load(); err != nil {
+			logrus.WithError(err).Warn("Unable to reload banned addresses, keeping the current ones")
+		} else {
+			logrus.Info("Reloaded banned addresses")
+		}
+	}, a.denyFile)
+}
+
+// allowed reports whether the client address passes the allow and deny lists. Addresses
+// that aren't IP addresses are only allowed when no allowlist is given.
+func (a *ipAccess) allowed(addr net.Addr) bool {
+	ip, ok := clientIp(addr)
+	if !ok {
+		return len(a.allow) == 0
+	}
+	if containsAddr(*a.deny.Load(), ip) {
+		return false
+	}
+	return len(a.allow) == 0 || containsAddr(a.allow, ip)
+}
+
+// blocks reports whether the action is blocked for the client address. It is safe to call
+// on a nil ipAccess, which blocks nothing.
+func (a *ipAccess) blocks(action string, addr net.Addr) bool {
+	if a == nil || !a.blocked[action] {
+		return false
+	}
+	return !a.allowed(addr)
+}
+
+// denied reports whether the client address is in the deny list or banned in the file
+func (a *ipAccess) denied(addr net.Addr) bool {
+	ip, ok := clientIp(addr)
+	return ok && containsAddr(*a.deny.Load(), ip)
+}
+
+// blocksConnection reports whether the client address can be turned away before reading
+// anything from it. Denied addresses are whenever any action is blocked, and addresses missing
+// from the allowlist when every kind of request is blocked; the actions blocked for the
+// others are checked once the request is read.
+func (a *ipAccess) blocksConnection(addr net.Addr) bool {
+	if a == nil || len(a.blocked) == 0 {
+		return false
+	}
+	if a.denied(addr) {
+		return true
+	}
+	return a.blocked[IpBlockStatus] && a.blocked[IpBlockLogin] && !a.allowed(addr)
+}
+
+func (a *ipAccess) close() {
+	a.cancel()
+	<-a.done
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/wroud/mc-motd/mcproto"
+)
+
+// maxJoinAttempts is how many of the most recent join attempts are kept
+const maxJoinAttempts = 100
+
+// JoinAttempt describes a join attempt that was allowed to wake the server
+type JoinAttempt struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Host      string      `json:"host"`
+	Server    string      `json:"server"`
+	Client    *ClientInfo `json:"client"`
+	Player    *PlayerInfo `json:"player,omitempty"`
+	Protocol  int         `json:"protocol"`
+	State     ServerState `json:"state"`
+}
+
+// joinAttemptLog keeps the most recent join attempts in a ring buffer
+type joinAttemptLog struct {
+	mu       sync.Mutex
+	attempts []JoinAttempt
+	next     int
+}
+
+func (l *joinAttemptLog) record(clientAddr net.Addr, handshake *mcproto.Handshake, host *virtualHost,
+	playerInfo *PlayerInfo, state ServerState) {
+
+	attempt := JoinAttempt{
+		Timestamp: time.Now(),
+		Host:      host.String(),
+		Server:    handshake.ServerAddress,
+		Client:    ClientInfoFromAddr(clientAddr),
+		Player:    playerInfo,
+		Protocol:  int(handshake.ProtocolVersion),
+		State:     state,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.attempts) < maxJoinAttempts {
+		l.attempts = append(l.attempts, attempt)
+	} else {
+		l.attempts[l.next] = attempt
+	}
+	l.next = (l.next + 1) % maxJoinAttempts
+}
+
+// recent returns up to limit join attempts, the most recent first
+func (l *joinAttemptLog) recent(limit int) []JoinAttempt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := min(limit, len(l.attempts))
+	result := make([]JoinAttempt, 0, count)
+	for i := 1; i <= count; i++ {
+		index := (l.next - i + len(l.attempts)) % len(l.attempts)
+		result = append(result, l.attempts[index])
+	}
+	return result
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/wroud/mc-motd/mcproto"
+)
+
+const (
+	limboSetupTimeout      = 10 * time.Second
+	limboWriteTimeout      = 5 * time.Second
+	limboTickInterval      = time.Second
+	limboKeepAliveInterval = 10 * time.Second
+	// limboSpawnHeight is above the build limit so the client never waits for the chunk below it
+	limboSpawnHeight = 400
+)
+
+// limboSession holds a player in an empty world, showing the startup progress in a boss bar and
+// the action bar, until the backend server is running and the player can be transferred to it.
+type limboSession struct {
+	conn            net.Conn
+	reader          *bufio.Reader
+	clientAddr      net.Addr
+	protocolVersion mcproto.ProtocolVersion
+	host            *virtualHost
+	playerInfo      *PlayerInfo
+	transferHost    string
+	transferPort    int
+}
+
+func (c *Connector) holdInLimbo(frontendConn net.Conn, clientAddr net.Addr, handshake *mcproto.Handshake,
+	host *virtualHost, playerInfo *PlayerInfo, bufferedReader *bufio.Reader) {
+
+	transferHost, transferPort, err := limboTransferAddress(&host.config.Limbo, handshake)
+	if err != nil {
+		logrus.WithError(err).WithField("client", clientAddr).Error("Invalid limbo transfer address")
+		return
+	}
+
+	// Read from the connection directly from now on, rather than through the inspection tee, which
+	// would otherwise keep everything the client sends while it waits
+	buffered, _ := bufferedReader.Peek(bufferedReader.Buffered())
+	session := &limboSession{
+		conn:            frontendConn,
+		reader:          bufio.NewReader(io.MultiReader(bytes.NewReader(buffered), frontendConn)),
+		clientAddr:      clientAddr,
+		protocolVersion: handshake.ProtocolVersion,
+		host:            host,
+		playerInfo:      playerInfo,
+		transferHost:    transferHost,
+		transferPort:    transferPort,
+	}
+
+	logrus.
+		WithField("client", clientAddr).
+		WithField("host", host).
+		WithField("player", playerInfo).
+		Info("Holding player in limbo until the server is running")
+
+	if err := session.configure(); err != nil {
+		logrus.WithError(err).
+			WithField("client", clientAddr).
+			WithField("player", playerInfo).
+			Warn("Unable to bring player into limbo")
+		return
+	}
+
+	if err := session.run(c.ctx.Done()); err != nil {
+		logrus.WithError(err).
+			WithField("client", clientAddr).
+			WithField("player", playerInfo).
+			Debug("Limbo session ended")
+	}
+}
+
+// limboTransferAddress returns the configured transfer address or else the address the client
+// connected to, without any suffix such as the one Forge clients append
+func limboTransferAddress(config *LimboConfig, handshake *mcproto.Handshake) (string, int, error) {
+	if config.TransferAddress == "" {
+		serverAddress, _, _ := strings.Cut(handshake.ServerAddress, "\x00")
+		return strings.TrimSuffix(serverAddress, "."), int(handshake.ServerPort), nil
+	}
+
+	host, portStr, err := net.SplitHostPort(config.TransferAddress)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %s: %w", portStr, err)
+	}
+	return host, port, nil
+}
+
+// configure completes the login and the configuration state, after which the
+// client is in the play state
+func (s *limboSession) configure() error {
+	if err := s.conn.SetDeadline(time.Now().Add(limboSetupTimeout)); err != nil {
+		return err
+	}
+
+	playerUuid := mcproto.OfflinePlayerUUID(s.playerInfo.Name)
+	if s.playerInfo.Verified {
+		// The UUID comes from the session server or the proxy in front
+		playerUuid = s.playerInfo.Uuid
+	}
+
+	err := mcproto.WriteLoginSuccess(s.conn, s.protocolVersion, playerUuid, s.playerInfo.Name)
+	if err != nil {
+		return fmt.Errorf("failed to write login success: %w", err)
+	}
+	if _, err := s.awaitPacket(mcproto.StateLogin, mcproto.PacketIdLoginAcknowledged); err != nil {
+		return err
+	}
+
+	if err := mcproto.WriteFeatureFlags(s.conn, []string{"minecraft:vanilla"}); err != nil {
+		return fmt.Errorf("failed to write feature flags: %w", err)
+	}
+
+	knownPack, err := s.selectKnownPack()
+	if err != nil {
+		return err
+	}
+	logrus.
+		WithField("client", s.clientAddr).
+		WithField("version", knownPack.Version).
+		Debug("Client has the core pack")
+
+	for _, registry := range mcproto.MinimalRegistries(s.protocolVersion) {
+		if err := mcproto.WriteRegistryData(s.conn, registry.Registry, registry.Entries); err != nil {
+			return fmt.Errorf("failed to write registry %s: %w", registry.Registry, err)
+		}
+	}
+	if err := mcproto.WriteEmptyTags(s.conn); err != nil {
+		return fmt.Errorf("failed to write tags: %w", err)
+	}
+	if err := mcproto.WriteFinishConfiguration(s.conn); err != nil {
+		return fmt.Errorf("failed to write finish configuration: %w", err)
+	}
+	if _, err := s.awaitPacket(mcproto.StateConfiguration, mcproto.PacketIdFinishConfiguration); err != nil {
+		return err
+	}
+
+	return s.conn.SetDeadline(noDeadline)
+}
+
+// selectKnownPack offers each core pack the client's protocol version may have until the client
+// confirms one, so that registry entries can be sent without their data
+func (s *limboSession) selectKnownPack() (*mcproto.KnownPack, error) {
+	for _, candidate := range mcproto.CoreKnownPacks(s.protocolVersion) {
+		if err := mcproto.WriteSelectKnownPacks(s.conn, []mcproto.KnownPack{candidate}); err != nil {
+			return nil, fmt.Errorf("failed to write known packs: %w", err)
+		}
+
+		packet, err := s.awaitPacket(mcproto.StateConfiguration, mcproto.PacketIdKnownPacks)
+		if err != nil {
+			return nil, err
+		}
+		packs, err := mcproto.DecodeKnownPacks(packet.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, pack := range packs {
+			if pack == candidate {
+				return &pack, nil
+			}
+		}
+	}
+
+	reason := mcproto.ParseText(s.host.motdManager.GetCurrentKickMessage())
+	if err := mcproto.WriteConfigurationDisconnect(s.conn, reason); err != nil {
+		return nil, fmt.Errorf("failed to write disconnect: %w", err)
+	}
+	return nil, fmt.Errorf("client has none of the core packs of protocol version %d", s.protocolVersion)
+}
+
+// awaitPacket reads packets until one with the given id arrives, skipping others such as the
+// client information and brand the client sends on its own
+func (s *limboSession) awaitPacket(state mcproto.State, packetId int) (*mcproto.Packet, error) {
+	for {
+		packet, err := mcproto.ReadPacket(s.reader, s.clientAddr, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packet: %w", err)
+		}
+		if packet.PacketID == packetId {
+			return packet, nil
+		}
+	}
+}
+
+// run keeps the player in an empty world until the server is running, the wait times out,
+// the client leaves or stop is closed
+func (s *limboSession) run(stop <-chan struct{}) error {
+	config := &s.host.config.Limbo
+	motdManager := s.host.motdManager
+
+	err := mcproto.WritePlayLogin(s.conn, s.protocolVersion, &mcproto.PlayLogin{
+		EntityId:            1,
+		MaxPlayers:          s.host.config.ServerStatus.MaxPlayers,
+		ViewDistance:        2,
+		DimensionName:       "minecraft:overworld",
+		GameMode:            mcproto.GameModeSpectator,
+		EnableRespawnScreen: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write login: %w", err)
+	}
+	if err := mcproto.WritePlayerPosition(s.conn, s.protocolVersion, 0, limboSpawnHeight, 0, 1); err != nil {
+		return fmt.Errorf("failed to write player position: %w", err)
+	}
+	if err := mcproto.WriteGameEvent(s.conn, s.protocolVersion, mcproto.GameEventStartWaitingForChunks, 0); err != nil {
+		return fmt.Errorf("failed to write game event: %w", err)
+	}
+
+	bossBarId := uuid.New()
+	title := motdManager.GetCurrentMOTD()
+	if err := mcproto.WriteBossBarAdd(s.conn, s.protocolVersion, bossBarId, mcproto.ParseText(title), 0, mcproto.BossBarYellow); err != nil {
+		return fmt.Errorf("failed to write boss bar: %w", err)
+	}
+
+	// Whatever the client sends is not needed, but it has to be read for the connection to stay open
+	clientGone := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := mcproto.ReadPacket(s.reader, s.clientAddr, mcproto.StatePlay); err != nil {
+				clientGone <- err
+				return
+			}
+		}
+	}()
+
+	started := time.Now()
+	timeout := time.Duration(config.Timeout) * time.Second
+	expected := time.Duration(s.host.config.ServerStatus.StartingTimeout) * time.Second
+	lastKeepAlive := started
+
+	ticker := time.NewTicker(limboTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-clientGone:
+			logrus.
+				WithField("client", s.clientAddr).
+				WithField("player", s.playerInfo).
+				Info("Player left limbo")
+			return err
+
+		case <-stop:
+			return s.disconnect(motdManager.GetCurrentKickMessage())
+
+		case now := <-ticker.C:
+			if err := s.conn.SetWriteDeadline(now.Add(limboWriteTimeout)); err != nil {
+				return err
+			}
+
+			if motdManager.GetCurrentState() == StateRunning {
+				logrus.
+					WithField("client", s.clientAddr).
+					WithField("player", s.playerInfo).
+					WithField("transferHost", s.transferHost).
+					WithField("transferPort", s.transferPort).
+					Info("Server is running, transferring player")
+				return mcproto.WriteTransfer(s.conn, s.protocolVersion, s.transferHost, s.transferPort)
+			}
+
+			elapsed := now.Sub(started)
+			if elapsed >= timeout {
+				logrus.
+					WithField("client", s.clientAddr).
+					WithField("player", s.playerInfo).
+					WithField("timeout", timeout).
+					Info("Server did not start in time, disconnecting player from limbo")
+				return s.disconnect(motdManager.GetCurrentKickMessage())
+			}
+
+			if now.Sub(lastKeepAlive) >= limboKeepAliveInterval {
+				if err := mcproto.WritePlayKeepAlive(s.conn, s.protocolVersion, now.UnixMilli()); err != nil {
+					return err
+				}
+				lastKeepAlive = now
+			}
+
+			if currentTitle := motdManager.GetCurrentMOTD(); currentTitle != title {
+				title = currentTitle
+				if err := mcproto.WriteBossBarTitle(s.conn, s.protocolVersion, bossBarId, mcproto.ParseText(title)); err != nil {
+					return err
+				}
+			}
+			if err := mcproto.WriteBossBarHealth(s.conn, s.protocolVersion, bossBarId, limboProgress(elapsed, expected)); err != nil {
+				return err
+			}
+
+			seconds := strconv.Itoa(int(elapsed.Seconds()))
+			progress := mcproto.ParseText(strings.ReplaceAll(config.ProgressMessage, "{elapsed}", seconds))
+			if err := mcproto.WriteActionBar(s.conn, s.protocolVersion, progress); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// limboProgress estimates how far the server is through starting up, never reaching
+// the end until it is actually running
+func limboProgress(elapsed, expected time.Duration) float32 {
+	if expected <= 0 {
+		return 0
+	}
+	progress := float32(elapsed) / float32(expected)
+	if progress > 0.99 {
+		return 0.99
+	}
+	return progress
+}
+
+func (s *limboSession) disconnect(reason string) error {
+	return mcproto.WritePlayDisconnect(s.conn, s.protocolVersion, mcproto.ParseText(reason))
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const metricsNamespace = "mc_motd"
+
+// Outcomes of accepted connections
+const (
+	connectionOutcomeHandled            = "handled"
+	connectionOutcomeBlocked            = "blocked"
+	connectionOutcomeRateLimited        = "rate_limited"
+	connectionOutcomeOverCapacity       = "over_capacity"
+	connectionOutcomeInvalidProxyHeader = "invalid_proxy_header"
+)
+
+var (
+	connectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "connections_total",
+		Help:      "Accepted connections by outcome",
+	}, []string{"outcome"})
+
+	statusRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "status_requests_total",
+		Help:      "Status pings answered",
+	}, []string{"host"})
+
+	legacyStatusRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "legacy_status_requests_total",
+		Help:      "Legacy status pings answered",
+	}, []string{"host"})
+
+	loginAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by protocol version",
+	}, []string{"host", "protocol"})
+
+	wakeAttemptsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "wake_attempts_total",
+		Help:      "Join attempts that were allowed to wake the server",
+	}, []string{"host"})
+
+	decodeErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decode_errors_total",
+		Help:      "Packets from clients that could not be decoded",
+	}, []string{"packet"})
+
+	webhookRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_requests_total",
+		Help:      "Webhook requests by outcome",
+	}, []string{"outcome"})
+
+	webhookEventsDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_events_dropped_total",
+		Help:      "Webhook events given up on without being delivered",
+	}, []string{"reason"})
+
+	webhookRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "webhook_request_duration_seconds",
+		Help:      "Latency of webhook requests",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+)
+
+// countDecodeError counts a packet that could not be read, unless the client just went away
+// or never sent anything
+func countDecodeError(packet string, err error) {
+	var netErr net.Error
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return
+	}
+	decodeErrorsTotal.WithLabelValues(packet).Inc()
+}
+
+// observeWebhookRequest records the outcome and latency of a webhook request started at start
+func observeWebhookRequest(start time.Time, success bool) {
+	outcome := "success"
+	if !success {
+		outcome = "failure"
+	}
+	webhookRequestsTotal.WithLabelValues(outcome).Inc()
+	webhookRequestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+var serverStateDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, "", "server_state"),
+	"The current state of each host, 1 for the state it is in and 0 for the others",
+	[]string{"host", "state"}, nil)
+
+// stateCollector reports the current state of every host when scraped
+type stateCollector struct {
+	connector *Connector
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serverStateDesc
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, host := range c.connector.hosts.Load().all() {
+		current := host.motdManager.GetCurrentState()
+		for _, state := range serverStates {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(serverStateDesc, prometheus.GaugeValue, value, host.String(), string(state))
+		}
+	}
+}
+
+// newMetricsRegistry creates the registry served on /metrics
+func newMetricsRegistry(connector *Connector) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		connectionsTotal,
+		statusRequestsTotal,
+		legacyStatusRequestsTotal,
+		loginAttemptsTotal,
+		wakeAttemptsTotal,
+		decodeErrorsTotal,
+		webhookRequestsTotal,
+		webhookEventsDroppedTotal,
+		webhookRequestDuration,
+		&stateCollector{connector: connector},
+	)
+	return registry
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"maps"
 	"sync"
 	"time"
 
@@ -10,23 +11,85 @@ import (
 type MOTDManager struct {
 	mu             sync.RWMutex
 	config         *ServerStatusConfig
+	favicons       map[ServerState]string
+	stateProvider  StateProvider
 	startingExpire time.Time
+	// forcedState is set through the admin API and takes precedence over any other state
+	forcedState ServerState
+	// failedState is set by a notifier that failed to start the server, below the forced state
+	failedState ServerState
+	// motds replaces the configured MOTD of some states, set through the admin API
+	motds map[ServerState]string
 }
 
-func NewMOTDManager(config *ServerStatusConfig) *MOTDManager {
-	return &MOTDManager{
-		config: config,
+// NewMOTDManager creates a manager for the given status configuration. If stateProvider is
+// not nil, the state it reports takes precedence over the one derived from join attempts.
+func NewMOTDManager(config *ServerStatusConfig, stateProvider StateProvider) (*MOTDManager, error) {
+	favicons, err := config.loadFavicons()
+	if err != nil {
+		return nil, err
 	}
+
+	return &MOTDManager{
+		config:        config,
+		favicons:      favicons,
+		stateProvider: stateProvider,
+		motds:         make(map[ServerState]string),
+	}, nil
 }
 
-func (m *MOTDManager) GetCurrentMOTD() string {
+// GetCurrentState returns the forced state if there is one, then the failed state, otherwise the
+// state reported by the state provider. If there is none or the state is not known, the server is considered starting
+// for StartingTimeout after a join attempt.
+func (m *MOTDManager) GetCurrentState() ServerState {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	if time.Now().Before(m.startingExpire) {
-		return m.config.StartingMOTD
+	if m.forcedState != "" {
+		return m.forcedState
+	}
+	if m.failedState != "" {
+		return m.failedState
+	}
+
+	starting := time.Now().Before(m.startingExpire)
+	if m.stateProvider != nil {
+		if state, known := m.stateProvider.State(); known {
+			// The provider may not have noticed the server waking up yet
+			if state == StateSleeping && starting {
+				return StateStarting
+			}
+			return state
+		}
+	}
+
+	if starting {
+		return StateStarting
+	}
+	return StateSleeping
+}
+
+func (m *MOTDManager) GetCurrentMOTD() string {
+	state := m.GetCurrentState()
+
+	m.mu.RLock()
+	motd, overridden := m.motds[state]
+	m.mu.RUnlock()
+	if overridden {
+		return motd
 	}
-	return m.config.SleepingMOTD
+	return m.config.motdFor(state)
+}
+
+// GetCurrentFavicon returns the data URI of the icon for the current state or an empty string
+// if none is configured.
+func (m *MOTDManager) GetCurrentFavicon() string {
+	return m.favicons[m.GetCurrentState()]
+}
+
+// GetCurrentKickMessage returns the message players are disconnected with in the current state
+func (m *MOTDManager) GetCurrentKickMessage() string {
+	return m.config.kickMessageFor(m.GetCurrentState())
 }
 
 func (m *MOTDManager) OnJoinAttempt() {
@@ -42,5 +105,95 @@ func (m *MOTDManager) OnJoinAttempt() {
 	}).Info("Join attempt received, server showing starting MOTD")
 }
 
+// SetState forces the state of the server over the state provider and join attempts until the
+// state is set again or ClearState is called
+func (m *MOTDManager) SetState(state ServerState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.forcedState = state
+
+	logrus.WithField("state", state).Info("Server state set")
+}
+
+// ClearState removes the forced state, if any
+func (m *MOTDManager) ClearState() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.forcedState = ""
+}
+
+// ForcedState returns the state forced with SetState or an empty string if there is none
+func (m *MOTDManager) ForcedState() ServerState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.forcedState
+}
+
+// SetFailedState sets the state shown after a failure to start the server until ClearFailedState
+// is called. Unlike SetState, it doesn't override a forced state.
+func (m *MOTDManager) SetFailedState(state ServerState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failedState = state
+
+	logrus.WithField("state", state).Info("Server state set after a failure")
+}
+
+// ClearFailedState removes the state set with SetFailedState, if any
+func (m *MOTDManager) ClearFailedState() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failedState = ""
+}
+
+// SetMOTD replaces the configured MOTD of the state. An empty MOTD restores the configured one.
+func (m *MOTDManager) SetMOTD(state ServerState, motd string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if motd == "" {
+		delete(m.motds, state)
+	} else {
+		m.motds[state] = motd
+	}
+}
+
+// MOTDs returns the MOTD shown in each state, including the ones replaced with SetMOTD
+func (m *MOTDManager) MOTDs() map[ServerState]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	motds := make(map[ServerState]string, len(serverStates))
+	for _, state := range serverStates {
+		if motd, overridden := m.motds[state]; overridden {
+			motds[state] = motd
+		} else {
+			motds[state] = m.config.motdFor(state)
+		}
+	}
+	return motds
+}
+
+// inheritState carries over the state of a manager that is being replaced due to a configuration reload
+func (m *MOTDManager) inheritState(previous *MOTDManager) {
+	previous.mu.RLock()
+	startingExpire := previous.startingExpire
+	forcedState := previous.forcedState
+	failedState := previous.failedState
+	motds := maps.Clone(previous.motds)
+	previous.mu.RUnlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.startingExpire = startingExpire
+	m.forcedState = forcedState
+	m.failedState = failedState
+	m.motds = motds
+}
+
 func (m *MOTDManager) Close() {
+	if m.stateProvider != nil {
+		m.stateProvider.Close()
+	}
 }
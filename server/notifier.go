@@ -2,14 +2,30 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons players are denied
+const (
+	DeniedReasonAddress  = "address"
+	DeniedReasonAuth     = "auth"
+	DeniedReasonPlayer   = "player"
+	DeniedReasonTransfer = "transfer"
 )
 
 type PlayerInfo struct {
 	Name string    `json:"name"`
 	Uuid uuid.UUID `json:"uuid"`
+	// Transferred is set when the player arrived through a Transfer packet from another server
+	Transferred bool `json:"-"`
+	// Verified is set when the UUID comes from the session server or a forwarding proxy
+	// rather than from the client
+	Verified bool `json:"-"`
 }
 
 func (p *PlayerInfo) String() string {
@@ -51,4 +67,98 @@ type ConnectionNotifier interface {
 	// NotifyDisconnected is called when the backend connection terminates.
 	NotifyDisconnected(ctx context.Context,
 		clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error
+
+	// NotifyStatusRequest is called when a status request was answered with the placeholder status.
+	NotifyStatusRequest(ctx context.Context, clientAddr net.Addr, serverAddress string) error
+
+	// NotifyDenied is called when a player is disconnected without being allowed to wake the server.
+	NotifyDenied(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, reason string) error
+
+	// NotifyStateChange is called when the state of the server changes.
+	NotifyStateChange(ctx context.Context, server string, previous, state ServerState) error
+}
+
+// CompositeNotifier implements ConnectionNotifier by passing each notification to all of its
+// notifiers, joining the errors they return
+type CompositeNotifier []ConnectionNotifier
+
+func (c CompositeNotifier) each(notify func(notifier ConnectionNotifier) error) error {
+	var errs []error
+	for _, notifier := range c {
+		if err := notify(notifier); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c CompositeNotifier) NotifyMissingBackend(ctx context.Context, clientAddr net.Addr, server string, playerInfo *PlayerInfo) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyMissingBackend(ctx, clientAddr, server, playerInfo)
+	})
+}
+
+func (c CompositeNotifier) NotifyFailedBackendConnection(ctx context.Context,
+	clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string, err error) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyFailedBackendConnection(ctx, clientAddr, serverAddress, playerInfo, backendHostPort, err)
+	})
+}
+
+func (c CompositeNotifier) NotifyConnected(ctx context.Context,
+	clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyConnected(ctx, clientAddr, serverAddress, playerInfo, backendHostPort)
+	})
+}
+
+func (c CompositeNotifier) NotifyDisconnected(ctx context.Context,
+	clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyDisconnected(ctx, clientAddr, serverAddress, playerInfo, backendHostPort)
+	})
+}
+
+func (c CompositeNotifier) NotifyStatusRequest(ctx context.Context, clientAddr net.Addr, serverAddress string) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyStatusRequest(ctx, clientAddr, serverAddress)
+	})
+}
+
+func (c CompositeNotifier) NotifyDenied(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, reason string) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyDenied(ctx, clientAddr, serverAddress, playerInfo, reason)
+	})
+}
+
+func (c CompositeNotifier) NotifyStateChange(ctx context.Context, server string, previous, state ServerState) error {
+	return c.each(func(notifier ConnectionNotifier) error {
+		return notifier.NotifyStateChange(ctx, server, previous, state)
+	})
+}
+
+// notifierGuards tracks whether a notifier of a host is busy starting the server, such as a
+// command still running or a container being started. The guards are kept across reloads, so
+// that the notifier of the reloaded host doesn't start the server again meanwhile.
+type notifierGuards struct {
+	mu     sync.Mutex
+	guards map[string]*atomic.Bool
+}
+
+func newNotifierGuards() *notifierGuards {
+	return &notifierGuards{guards: make(map[string]*atomic.Bool)}
+}
+
+// get returns the guard of the kind of notifier, such as exec or docker, for the host
+func (g *notifierGuards) get(kind, host string) *atomic.Bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := kind + "/" + host
+	guard, exists := g.guards[key]
+	if !exists {
+		guard = &atomic.Bool{}
+		g.guards[key] = guard
+	}
+	return guard
 }
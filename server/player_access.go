@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const playerListReloadInterval = 5 * time.Second
+
+// banExpiryLayout is the date format of the expires field in banned-players.json
+const banExpiryLayout = "2006-01-02 15:04:05 -0700"
+
+// playerSet matches players by name, ignoring case, or by UUID
+type playerSet struct {
+	names map[string]struct{}
+	uuids map[uuid.UUID]struct{}
+}
+
+func newPlayerSet() *playerSet {
+	return &playerSet{
+		names: make(map[string]struct{}),
+		uuids: make(map[uuid.UUID]struct{}),
+	}
+}
+
+// add adds an entry given either as a name or as a UUID, with or without dashes
+func (s *playerSet) add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return
+	}
+	if id, err := uuid.Parse(entry); err == nil {
+		s.uuids[id] = struct{}{}
+		return
+	}
+	s.names[strings.ToLower(entry)] = struct{}{}
+}
+
+func (s *playerSet) contains(player *PlayerInfo) bool {
+	if _, exists := s.names[strings.ToLower(player.Name)]; exists {
+		return true
+	}
+	if player.Uuid != uuid.Nil {
+		if _, exists := s.uuids[player.Uuid]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// playerListEntry is an entry of the vanilla whitelist.json and banned-players.json files
+type playerListEntry struct {
+	Uuid    string `json:"uuid"`
+	Name    string `json:"name"`
+	Expires string `json:"expires,omitempty"`
+}
+
+// banExpired reports whether the expires field of a vanilla ban entry lies in the past
+func banExpired(expires string, now time.Time) bool {
+	if expires == "" || expires == "forever" {
+		return false
+	}
+	expiresAt, err := time.Parse(banExpiryLayout, expires)
+	return err == nil && expiresAt.Before(now)
+}
+
+// loadPlayerList adds the entries of a whitelist.json or banned-players.json file to the set,
+// skipping bans that have expired
+func loadPlayerList(file *watchedFile, set *playerSet) error {
+	var entries []playerListEntry
+	if err := file.decode(&entries); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if banExpired(entry.Expires, now) {
+			continue
+		}
+		if entry.Uuid != "" {
+			set.add(entry.Uuid)
+		}
+		if entry.Name != "" {
+			set.add(entry.Name)
+		}
+	}
+	return nil
+}
+
+// playerLists are the allowlist and denylist, replaced together when the files change
+type playerLists struct {
+	// allow is nil when every player that isn't denied is allowed
+	allow *playerSet
+	deny  *playerSet
+}
+
+// playerAccess decides which players are allowed to wake the server from an allowlist and a
+// denylist, each given in the configuration and as a vanilla player list file
+type playerAccess struct {
+	config    *PlayersConfig
+	allowFile *watchedFile
+	denyFile  *watchedFile
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	lists atomic.Pointer[playerLists]
+}
+
+// newPlayerAccess loads the configured player lists or returns nil if there are none
+func newPlayerAccess(config *PlayersConfig) (*playerAccess, error) {
+	if !config.enabled() {
+		return nil, nil
+	}
+
+	a := &playerAccess{
+		config: config,
+		done:   make(chan struct{}),
+	}
+	if config.AllowFile != "" {
+		a.allowFile = newWatchedFile(config.AllowFile)
+	}
+	if config.DenyFile != "" {
+		a.denyFile = newWatchedFile(config.DenyFile)
+	}
+
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	ctx, a.cancel = context.WithCancel(context.Background())
+	go a.watch(ctx)
+	return a, nil
+}
+
+// load reads both lists before replacing the current ones, which are kept if either fails
+func (a *playerAccess) load() error {
+	lists := &playerLists{deny: newPlayerSet()}
+	if len(a.config.Allow) > 0 || a.allowFile != nil {
+		lists.allow = newPlayerSet()
+		for _, entry := range a.config.Allow {
+			lists.allow.add(entry)
+		}
+		if a.allowFile != nil {
+			if err := loadPlayerList(a.allowFile, lists.allow); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, entry := range a.config.Deny {
+		lists.deny.add(entry)
+	}
+	if a.denyFile != nil {
+		if err := loadPlayerList(a.denyFile, lists.deny); err != nil {
+			return err
+		}
+	}
+
+	a.lists.Store(lists)
+	return nil
+}
+
+// watch reloads the player lists when either file changes, keeping the previous lists if the
+// files can't be read
+func (a *playerAccess) watch(ctx context.Context) {
+	defer close(a.done)
+
+	var files []*watchedFile
+	for _, file := range []*watchedFile{a.allowFile, a.denyFile} {
+		if file != nil {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+
+	watchFiles(ctx, playerListReloadInterval, func() {
+		if err := a.load(); err != nil {
+			logrus.WithError(err).Warn("Unable to reload player lists, keeping the current ones")
+		} else {
+			logrus.Info("Reloaded player lists")
+		}
+	}, files...)
+}
+
+// allowed reports whether the player may wake the server
+func (a *playerAccess) allowed(player *PlayerInfo) bool {
+	if player == nil {
+		return false
+	}
+	lists := a.lists.Load()
+	if lists.deny.contains(player) {
+		return false
+	}
+	if lists.allow != nil {
+		return lists.allow.contains(player)
+	}
+	return true
+}
+
+func (a *playerAccess) close() {
+	a.cancel()
+	<-a.done
+}
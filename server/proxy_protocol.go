@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// proxyProtocolV2Signature starts every PROXY protocol version 2 header
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// proxyProtocolV1MaxLength is the longest version 1 header, including the CRLF
+	proxyProtocolV1MaxLength = 107
+
+	proxyProtocolV2CommandLocal = 0x0
+	proxyProtocolV2CommandProxy = 0x1
+	proxyProtocolV2FamilyInet   = 0x1
+	proxyProtocolV2FamilyInet6  = 0x2
+)
+
+// proxiedConn is a connection received through a proxy that reports the address of the
+// client given in the PROXY protocol header as its remote address
+type proxiedConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxiedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// readProxyHeader reads a PROXY protocol version 1 or 2 header from the connection. The
+// returned connection reports the client address from the header, or the address of the
+// proxy itself for headers that don't carry one, such as health checks.
+func readProxyHeader(conn net.Conn) (net.Conn, error) {
+	reader := bufio.NewReader(conn)
+
+	start, err := reader.Peek(len(proxyProtocolV2Signature))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy protocol header: %w", err)
+	}
+
+	var clientAddr net.Addr
+	switch {
+	case bytes.Equal(start, proxyProtocolV2Signature):
+		clientAddr, err = readProxyHeaderV2(reader)
+	case bytes.HasPrefix(start, []byte("PROXY ")):
+		clientAddr, err = readProxyHeaderV1(reader)
+	default:
+		return nil, fmt.Errorf("missing proxy protocol header")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if clientAddr == nil {
+		clientAddr = conn.RemoteAddr()
+	}
+	return &proxiedConn{Conn: conn, reader: reader, remoteAddr: clientAddr}, nil
+}
+
+// readProxyHeaderV1 reads a header such as "PROXY TCP4 192.0.2.1 198.51.100.1 56324 25565\r\n"
+func readProxyHeaderV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, fmt.Errorf("proxy protocol v1 header is too long")
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxy protocol v1 header: %w", err)
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid proxy protocol v1 header %q", strings.TrimSpace(string(line)))
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy protocol v1 source address: %w", err)
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy protocol v1 source port: %w", err)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+// readProxyHeaderV2 reads a binary header, skipping any TLVs it carries
+func readProxyHeaderV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxyProtocolV2Signature)+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read proxy protocol v2 header: %w", err)
+	}
+
+	versionCommand := header[12]
+	family := header[13] >> 4
+	length := binary.BigEndian.Uint16(header[14:16])
+	if versionCommand>>4 != 2 {
+		return nil, fmt.Errorf("unsupported proxy protocol version %d", versionCommand>>4)
+	}
+
+	addresses := make([]byte, length)
+	if _, err := io.ReadFull(reader, addresses); err != nil {
+		return nil, fmt.Errorf("failed to read proxy protocol v2 addresses: %w", err)
+	}
+
+	switch versionCommand & 0x0F {
+	case proxyProtocolV2CommandLocal:
+		return nil, nil
+	case proxyProtocolV2CommandProxy:
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol v2 command %d", versionCommand&0x0F)
+	}
+
+	switch family {
+	case proxyProtocolV2FamilyInet:
+		if len(addresses) < 12 {
+			return nil, fmt.Errorf("truncated proxy protocol v2 IPv4 addresses")
+		}
+		ip := netip.AddrFrom4([4]byte(addresses[0:4]))
+		port := binary.BigEndian.Uint16(addresses[8:10])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+	case proxyProtocolV2FamilyInet6:
+		if len(addresses) < 36 {
+			return nil, fmt.Errorf("truncated proxy protocol v2 IPv6 addresses")
+		}
+		ip := netip.AddrFrom16([16]byte(addresses[0:16])).Unmap()
+		port := binary.BigEndian.Uint16(addresses[32:34])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+	default:
+		// Unix sockets and unspecified families carry no usable client address
+		return nil, nil
+	}
+}
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Ways of handling connections beyond the rate limits
+const (
+	ExcessClose  = "close"
+	ExcessTarpit = "tarpit"
+)
+
+const (
+	rateLimitReportInterval = 10 * time.Second
+	// maxTarpitted bounds the connections held open by the tarpit, beyond which they are closed
+	maxTarpitted = 1024
+)
+
+// tokenBucket refills at a rate of tokens per second up to its burst size
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket will have refilled to its burst size
+	full time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time, rate float64, burst int) {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+}
+
+// take refills the bucket and takes a token from it if there is one
+func (b *tokenBucket) take(now time.Time, rate float64, burst int) bool {
+	b.refill(now, rate, burst)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	return true
+}
+
+// rateLimitKey groups IPv6 clients by /64, since a single host commonly has a whole /64
+func rateLimitKey(addr net.Addr) (netip.Addr, bool) {
+	ip, ok := clientIp(addr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	if ip.Is6() {
+		prefix, _ := ip.Prefix(64)
+		return prefix.Addr(), true
+	}
+	return ip, true
+}
+
+// connectionLimiter applies per address and global token bucket rate limits to accepted
+// connections and caps the number of connections handled at the same time. Its state is
+// kept across configuration reloads.
+type connectionLimiter struct {
+	mu     sync.Mutex
+	global tokenBucket
+	perIp  map[netip.Addr]*tokenBucket
+
+	inFlight  atomic.Int64
+	tarpitted atomic.Int64
+
+	rateLimited  atomic.Int64
+	overCapacity atomic.Int64
+}
+
+func newConnectionLimiter(ctx context.Context) *connectionLimiter {
+	l := &connectionLimiter{
+		perIp: make(map[netip.Addr]*tokenBucket),
+	}
+	go l.report(ctx)
+	return l
+}
+
+// admit decides whether the connection may be handled. If it may not, the connection is
+// closed or tarpitted and false is returned. Each admitted connection must be released.
+func (l *connectionLimiter) admit(conn net.Conn, config *RateLimitConfig) bool {
+	if !l.allowPerIp(conn.RemoteAddr(), config) || !l.allowGlobal(config) {
+		l.refuseRate(conn, config)
+		return false
+	}
+	return l.acquire(conn, config)
+}
+
+// admitProxied applies the global limits to a connection from a trusted proxy before its PROXY
+// header is read. The per address limit is applied to the client address by admitClient once
+// the header is read. Each admitted connection must be released.
+func (l *connectionLimiter) admitProxied(conn net.Conn, config *RateLimitConfig) bool {
+	if !l.allowGlobal(config) {
+		l.refuseRate(conn, config)
+		return false
+	}
+	return l.acquire(conn, config)
+}
+
+// admitClient applies the per address limit to the client of a connection admitted by
+// admitProxied, which still needs to be released if it is refused
+func (l *connectionLimiter) admitClient(conn net.Conn, config *RateLimitConfig) bool {
+	if !l.allowPerIp(conn.RemoteAddr(), config) {
+		l.refuseRate(conn, config)
+		return false
+	}
+	return true
+}
+
+// acquire counts the connection as in flight unless there are too many already
+func (l *connectionLimiter) acquire(conn net.Conn, config *RateLimitConfig) bool {
+	inFlight := l.inFlight.Add(1)
+	if config.MaxConnections > 0 && inFlight > int64(config.MaxConnections) {
+		l.inFlight.Add(-1)
+		l.overCapacity.Add(1)
+		connectionsTotal.WithLabelValues(connectionOutcomeOverCapacity).Inc()
+		logrus.WithField("client", conn.RemoteAddr()).
+			WithField("inFlight", inFlight-1).
+			Debug("Too many connections in flight")
+		l.reject(conn, config)
+		return false
+	}
+	return true
+}
+
+// release marks an admitted connection as done
+func (l *connectionLimiter) release() {
+	l.inFlight.Add(-1)
+}
+
+func (l *connectionLimiter) refuseRate(conn net.Conn, config *RateLimitConfig) {
+	l.rateLimited.Add(1)
+	connectionsTotal.WithLabelValues(connectionOutcomeRateLimited).Inc()
+	logrus.WithField("client", conn.RemoteAddr()).Debug("Connection exceeds rate limit")
+	l.reject(conn, config)
+}
+
+// allowPerIp takes a token from the bucket of the client address
+func (l *connectionLimiter) allowPerIp(clientAddr net.Addr, config *RateLimitConfig) bool {
+	if config.PerIp <= 0 {
+		return true
+	}
+	// Loopback addresses are exempt, so that health checks aren't turned away
+	key, ok := rateLimitKey(clientAddr)
+	if !ok || key.IsLoopback() {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, exists := l.perIp[key]
+	if !exists {
+		bucket = &tokenBucket{}
+		l.perIp[key] = bucket
+	}
+	return bucket.take(time.Now(), config.PerIp, config.PerIpBurst)
+}
+
+// allowGlobal takes a token from the bucket shared by all connections
+func (l *connectionLimiter) allowGlobal(config *RateLimitConfig) bool {
+	if config.Global <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.global.take(time.Now(), config.Global, config.GlobalBurst)
+}
+
+// reject closes the connection or, when tarpitting, leaves it open without reading from it
+// until the tarpit timeout so that the client wastes its time rather than ours
+func (l *connectionLimiter) reject(conn net.Conn, config *RateLimitConfig) {
+	if config.Excess != ExcessTarpit || l.tarpitted.Add(1) > maxTarpitted {
+		if config.Excess == ExcessTarpit {
+			l.tarpitted.Add(-1)
+		}
+		conn.Close()
+		return
+	}
+
+	time.AfterFunc(time.Duration(config.TarpitTimeout)*time.Second, func() {
+		conn.Close()
+		l.tarpitted.Add(-1)
+	})
+}
+
+// report periodically logs how many connections were turned away and forgets the addresses
+// whose buckets have refilled, since a new bucket starts out full anyway
+func (l *connectionLimiter) report(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rateLimited := l.rateLimited.Swap(0)
+			overCapacity := l.overCapacity.Swap(0)
+			if rateLimited > 0 || overCapacity > 0 {
+				logrus.
+					WithField("rateLimited", rateLimited).
+					WithField("overCapacity", overCapacity).
+					WithField("tarpitted", l.tarpitted.Load()).
+					WithField("interval", rateLimitReportInterval).
+					Warn("Turned away excess connections")
+			}
+			l.prune(time.Now())
+		}
+	}
+}
+
+func (l *connectionLimiter) prune(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, bucket := range l.perIp {
+		if now.After(bucket.full) {
+			delete(l.perIp, key)
+		}
+	}
+}
@@ -4,36 +4,65 @@ import (
 	"context"
 	"net"
 	"strconv"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
 
 type Server struct {
-	ctx         context.Context
-	config      *Config
-	connector   *Connector
-	motdManager *MOTDManager
-	doneChan    chan struct{}
+	ctx       context.Context
+	connector *Connector
+	doneChan  chan struct{}
+
+	mu       sync.Mutex
+	config   *Config
+	hosts    *hostRegistry
+	ipAccess *ipAccess
+	// webhookQueue is kept across reloads so that pending webhook events are still delivered
+	webhookQueue *WebhookQueue
+	// guards are kept across reloads so that notifiers still starting the server don't start it again
+	guards *notifierGuards
 }
 
 func NewServer(ctx context.Context, config *Config) (*Server, error) {
-	motdManager := NewMOTDManager(&config.ServerStatus)
-	connector := NewConnector(ctx, config, motdManager)
-
-	if config.Webhook.Url != "" {
-		logrus.WithField("url", config.Webhook.Url).
-			WithField("require-user", config.Webhook.RequireUser).
-			Info("Using webhook for connection status notifications")
-		connector.UseConnectionNotifier(
-			NewWebhookNotifier(config.Webhook.Url, config.Webhook.RequireUser))
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	webhookQueue, err := NewWebhookQueue(ctx, &config.WebhookQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	guards := newNotifierGuards()
+	hosts, err := newHostRegistry(config, webhookQueue, guards)
+	if err != nil {
+		return nil, err
+	}
+
+	ipAccess, err := newIpAccess(&config.Ip)
+	if err != nil {
+		hosts.close()
+		return nil, err
+	}
+
+	// The secrets of the targets are known once the hosts are created
+	if err := webhookQueue.replay(); err != nil {
+		hosts.close()
+		return nil, err
 	}
 
+	connector := NewConnector(ctx, config, hosts, ipAccess)
+
 	return &Server{
-		ctx:         ctx,
-		config:      config,
-		connector:   connector,
-		motdManager: motdManager,
-		doneChan:    make(chan struct{}),
+		ctx:          ctx,
+		config:       config,
+		connector:    connector,
+		hosts:        hosts,
+		ipAccess:     ipAccess,
+		webhookQueue: webhookQueue,
+		guards:       guards,
+		doneChan:     make(chan struct{}),
 	}, nil
 }
 
@@ -55,10 +84,32 @@ func (s *Server) AcceptConnection(conn net.Conn) {
 // Run will run the server until the context is done or a fatal error occurs, so this should be
 // in a go routine.
 func (s *Server) Run() {
-	defer s.motdManager.Close() // Clean up MOTD manager when server stops
+	defer func() {
+		// Clean up MOTD managers when server stops
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.hosts.close()
+		if s.ipAccess != nil {
+			s.ipAccess.close()
+		}
+	}()
+
+	s.mu.Lock()
+	port := s.config.Port
+	httpAddress := s.config.Http.Address
+	s.mu.Unlock()
+
+	// Start serving HTTP first so that /readyz can tell the listener isn't up yet
+	if httpAddress != "" {
+		if err := s.startHttpServer(httpAddress); err != nil {
+			logrus.WithError(err).Error("Could not start the HTTP server")
+			s.notifyDone()
+			return
+		}
+	}
 
 	err := s.connector.StartAcceptingConnections(
-		net.JoinHostPort("", strconv.Itoa(s.config.Port)),
+		net.JoinHostPort("", strconv.Itoa(port)),
 	)
 	if err != nil {
 		logrus.WithError(err).Error("Could not start accepting connections")
@@ -70,3 +121,55 @@ func (s *Server) Run() {
 	logrus.Info("Stopped")
 	s.notifyDone()
 }
+
+// Reload applies a new configuration without interrupting the listener. Virtual hosts that
+// remain configured keep their current state. If the new configuration is invalid, an error
+// is returned and the current configuration stays active.
+func (s *Server) Reload(config *Config) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	hosts, err := newHostRegistry(config, s.webhookQueue, s.guards)
+	if err != nil {
+		return err
+	}
+
+	ipAccess, err := newIpAccess(&config.Ip)
+	if err != nil {
+		hosts.close()
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if config.Port != s.config.Port {
+		logrus.
+			WithField("port", s.config.Port).
+			WithField("newPort", config.Port).
+			Warn("Changing the port requires a restart, continuing to listen on the current port")
+		config.Port = s.config.Port
+	}
+	if config.Http.Address != s.config.Http.Address {
+		logrus.
+			WithField("address", s.config.Http.Address).
+			WithField("newAddress", config.Http.Address).
+			Warn("Changing the HTTP address requires a restart, continuing to use the current address")
+		config.Http.Address = s.config.Http.Address
+	}
+
+	hosts.inheritState(s.hosts)
+	previous, previousIpAccess := s.hosts, s.ipAccess
+	s.config = config
+	s.hosts = hosts
+	s.ipAccess = ipAccess
+	s.connector.swap(config, hosts, ipAccess)
+	s.webhookQueue.configure(&config.WebhookQueue)
+	previous.close()
+	if previousIpAccess != nil {
+		previousIpAccess.close()
+	}
+
+	return nil
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ServerState is the state of the backend server that decides which MOTD and kick message are shown
+type ServerState string
+
+const (
+	StateSleeping ServerState = "sleeping"
+	StateStarting ServerState = "starting"
+	StateRunning  ServerState = "running"
+	StateStopping ServerState = "stopping"
+	StateCrashed  ServerState = "crashed"
+)
+
+var serverStates = []ServerState{StateSleeping, StateStarting, StateRunning, StateStopping, StateCrashed}
+
+// ParseServerState converts a state name, as reported by a state provider, to a ServerState
+func ParseServerState(name string) (ServerState, error) {
+	state := ServerState(strings.ToLower(strings.TrimSpace(name)))
+	if slices.Contains(serverStates, state) {
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown server state %q", name)
+}
+
+// StateProvider reports the actual state of the backend server
+type StateProvider interface {
+	// State returns the most recently observed state of the backend server. If the state
+	// is not known, known is false and the state is derived from join attempts instead.
+	State() (state ServerState, known bool)
+	Close()
+}
+
+const (
+	StateProviderHttp   = "http"
+	StateProviderFile   = "file"
+	StateProviderPing   = "ping"
+	StateProviderDocker = "docker"
+)
+
+// NewStateProvider creates the state provider declared by the host configuration or
+// returns nil if none is configured
+func NewStateProvider(config *HostConfig) (StateProvider, error) {
+	interval := time.Duration(config.StateProvider.Interval) * time.Second
+
+	switch config.stateProviderType() {
+	case "":
+		return nil, nil
+	case StateProviderHttp:
+		return newPollingStateProvider(interval, newHttpStateProbe(config.StateProvider.Url)), nil
+	case StateProviderFile:
+		return newPollingStateProvider(interval, newFileStateProbe(config.StateProvider.File)), nil
+	case StateProviderPing:
+		return newPollingStateProvider(interval, newPingStateProbe(config.Backend.Address)), nil
+	case StateProviderDocker:
+		client := newDockerClient(config.Docker.Socket, time.Duration(config.Docker.Timeout)*time.Second)
+		return newPollingStateProvider(interval, newDockerStateProbe(client, config.Docker.Container)), nil
+	default:
+		return nil, fmt.Errorf("unknown state provider type %q", config.StateProvider.Type)
+	}
+}
+
+// stateProbe checks the backend server once. It returns known as false if the probe can't tell
+// the state, such as when the ping provider can't reach the server.
+type stateProbe interface {
+	probe(ctx context.Context) (state ServerState, known bool, err error)
+	String() string
+}
+
+// pollingStateProvider runs a probe at a fixed interval and keeps its latest result
+type pollingStateProvider struct {
+	probe  stateProbe
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.RWMutex
+	state ServerState
+	known bool
+}
+
+func newPollingStateProvider(interval time.Duration, probe stateProbe) *pollingStateProvider {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pollingStateProvider{
+		probe:  probe,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go p.run(ctx, interval)
+	return p
+}
+
+func (p *pollingStateProvider) run(ctx context.Context, interval time.Duration) {
+	defer close(p.done)
+
+	p.poll(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.poll(ctx)
+		}
+	}
+}
+
+func (p *pollingStateProvider) poll(ctx context.Context) {
+	state, known, err := p.probe.probe(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logrus.WithError(err).
+			WithField("provider", p.probe).
+			Debug("Unable to determine backend server state")
+		known = false
+	}
+
+	p.mu.Lock()
+	changed := known != p.known || state != p.state
+	p.state, p.known = state, known
+	p.mu.Unlock()
+
+	if changed {
+		if known {
+			logrus.WithField("provider", p.probe).
+				WithField("state", state).
+				Info("Backend server state changed")
+		} else {
+			logrus.WithField("provider", p.probe).
+				Info("Backend server state is unknown, using join attempts to determine it")
+		}
+	}
+}
+
+func (p *pollingStateProvider) State() (ServerState, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.state, p.known
+}
+
+func (p *pollingStateProvider) Close() {
+	p.cancel()
+	<-p.done
+}
+
+// httpStateProbe polls a URL that responds with the state name, either as plain text
+// or as a JSON object such as {"state":"running"}
+type httpStateProbe struct {
+	url    string
+	client *http.Client
+}
+
+func newHttpStateProbe(url string) *httpStateProbe {
+	return &httpStateProbe{
+		url:    url,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (h *httpStateProbe) probe(ctx context.Context) (ServerState, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", false, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", false, err
+	}
+
+	name := strings.TrimSpace(string(body))
+	if strings.HasPrefix(name, "{") {
+		var payload struct {
+			State string `json:"state"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return "", false, fmt.Errorf("invalid state json: %w", err)
+		}
+		name = payload.State
+	}
+
+	state, err := ParseServerState(name)
+	if err != nil {
+		return "", false, err
+	}
+	return state, true, nil
+}
+
+func (h *httpStateProbe) String() string {
+	return h.url
+}
+
+// fileStateProbe reads the state name from a file, such as one written by the
+// script that manages the backend server. A missing file means the state is unknown.
+// The file is only read again when it changes.
+type fileStateProbe struct {
+	file *watchedFile
+	read bool
+
+	state ServerState
+	known bool
+	err   error
+}
+
+func newFileStateProbe(path string) *fileStateProbe {
+	return &fileStateProbe{file: newWatchedFile(path)}
+}
+
+func (f *fileStateProbe) probe(context.Context) (ServerState, bool, error) {
+	if f.file.changed() || !f.read {
+		f.state, f.known, f.err = f.readState()
+		f.read = true
+	}
+	return f.state, f.known, f.err
+}
+
+func (f *fileStateProbe) readState() (ServerState, bool, error) {
+	content, err := os.ReadFile(f.file.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	state, err := ParseServerState(string(content))
+	if err != nil {
+		return "", false, err
+	}
+	return state, true, nil
+}
+
+func (f *fileStateProbe) String() string {
+	return f.file.path
+}
+
+// pingStateProbe sends status requests to the backend server. A response means it is running;
+// otherwise the state is unknown since a server that can't be reached may be sleeping or starting.
+type pingStateProbe struct {
+	address string
+}
+
+func newPingStateProbe(address string) *pingStateProbe {
+	return &pingStateProbe{address: address}
+}
+
+func (p *pingStateProbe) probe(ctx context.Context) (ServerState, bool, error) {
+	if _, err := PingBackend(ctx, p.address); err != nil {
+		return "", false, nil
+	}
+	return StateRunning, true, nil
+}
+
+func (p *pingStateProbe) String() string {
+	return p.address
+}
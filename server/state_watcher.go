@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stateWatchInterval is how often the state of a host is checked for changes
+const stateWatchInterval = time.Second
+
+// stateWatcher notifies the notifier of a host when its server state changes. The state is
+// derived on demand from the state provider, join attempts and the admin API, so it is polled.
+type stateWatcher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newStateWatcher(host *virtualHost, notifier ConnectionNotifier) *stateWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &stateWatcher{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go w.run(ctx, host, notifier)
+	return w
+}
+
+func (w *stateWatcher) run(ctx context.Context, host *virtualHost, notifier ConnectionNotifier) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(stateWatchInterval)
+	defer ticker.Stop()
+
+	// The first state is only recorded, since hosts inherit their state after being created
+	var previous ServerState
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		state := host.motdManager.GetCurrentState()
+		if previous != "" && state != previous {
+			logrus.
+				WithField("host", host).
+				WithField("previous", previous).
+				WithField("state", state).
+				Debug("Server state changed")
+			if err := notifier.NotifyStateChange(ctx, host.String(), previous, state); err != nil {
+				logrus.WithError(err).Warn("failed to notify state change")
+			}
+		}
+		previous = state
+	}
+}
+
+func (w *stateWatcher) close() {
+	w.cancel()
+	<-w.done
+}
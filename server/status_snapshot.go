@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/wroud/mc-motd/mcproto"
+)
+
+// statusMirror periodically pings the backend server while it is up and keeps the last status it
+// responded with, both in memory and on disk so that it survives restarts. The snapshot keeps
+// the version, favicon, forgeData, modinfo and any other fields of the real server so that
+// clients, including modded ones, see the same server while it is down.
+type statusMirror struct {
+	address string
+	path    string
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu       sync.RWMutex
+	snapshot json.RawMessage
+}
+
+func newStatusMirror(address, path string, interval time.Duration) *statusMirror {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &statusMirror{
+		address: address,
+		path:    path,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	if snapshot, err := os.ReadFile(path); err == nil {
+		if json.Valid(snapshot) {
+			m.snapshot = snapshot
+			logrus.WithField("file", path).Debug("Loaded status snapshot")
+		} else {
+			logrus.WithField("file", path).Warn("Ignoring status snapshot that is not valid JSON")
+		}
+	} else if !os.IsNotExist(err) {
+		logrus.WithError(err).WithField("file", path).Warn("Unable to read status snapshot")
+	}
+
+	go m.run(ctx, interval)
+	return m
+}
+
+func (m *statusMirror) run(ctx context.Context, interval time.Duration) {
+	defer close(m.done)
+
+	m.update(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.update(ctx)
+		}
+	}
+}
+
+func (m *statusMirror) update(ctx context.Context) {
+	status, err := PingBackend(ctx, m.address)
+	if err != nil {
+		// The backend being down is when the snapshot is needed, so keep the previous one
+		return
+	}
+
+	m.mu.Lock()
+	changed := !bytes.Equal(status, m.snapshot)
+	m.snapshot = status
+	m.mu.Unlock()
+
+	if changed {
+		if err := m.save(status); err != nil {
+			logrus.WithError(err).WithField("file", m.path).Warn("Unable to save status snapshot")
+		}
+	}
+}
+
+// save writes the snapshot to a temporary file first so that a crash never leaves a partial one
+func (m *statusMirror) save(status json.RawMessage) error {
+	return writeFileAtomic(m.path, status)
+}
+
+// writeFileAtomic writes the data to a temporary file in the same directory first and renames it
+// over the file, so that a crash never leaves a partial file behind
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// render returns the snapshot with its description replaced by the given MOTD and no players
+// online, or nil if no snapshot has been taken yet. A non-empty favicon replaces the one of the
+// snapshot.
+func (m *statusMirror) render(motd *mcproto.TextComponent, favicon string) ([]byte, error) {
+	m.mu.RLock()
+	snapshot := m.snapshot
+	m.mu.RUnlock()
+
+	if snapshot == nil {
+		return nil, nil
+	}
+
+	var status map[string]json.RawMessage
+	if err := json.Unmarshal(snapshot, &status); err != nil {
+		return nil, fmt.Errorf("invalid status snapshot: %w", err)
+	}
+
+	description, err := json.Marshal(motd)
+	if err != nil {
+		return nil, err
+	}
+	status["description"] = description
+
+	if favicon != "" {
+		if status["favicon"], err = json.Marshal(favicon); err != nil {
+			return nil, err
+		}
+	}
+
+	var players struct {
+		Max int `json:"max"`
+	}
+	if raw, exists := status["players"]; exists {
+		// A malformed players object is replaced below either way
+		_ = json.Unmarshal(raw, &players)
+	}
+	if status["players"], err = json.Marshal(map[string]int{"max": players.Max, "online": 0}); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(status)
+}
+
+func (m *statusMirror) close() {
+	m.cancel()
+	<-m.done
+}
@@ -1,61 +1,182 @@
 package server
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/sirupsen/logrus"
-	"log"
+	"maps"
 	"net"
-	"net/http"
+	"slices"
+	"strings"
+	"text/template"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// webhookTimeout bounds each webhook request unless the target configures a timeout
+const webhookTimeout = 30 * time.Second
+
 // WebhookNotifier implements ConnectionNotifier by sending a POST request to a webhook URL.
-// The payload is a JSON object defined by WebhookNotifierPayload.
+// The payload is a JSON object defined by WebhookNotifierPayload, unless a template renders
+// the body. Requests are delivered by the queue, which retries them until the receiver
+// accepts them.
 type WebhookNotifier struct {
-	url         string
-	requireUser bool
+	*payloadNotifier
+	url     string
+	headers map[string]string
+	timeout time.Duration
+	// target names the target in the queue, which signs its requests with the current secret
+	target string
+	signed bool
+	// template renders the body, which is the JSON payload when it is nil
+	template    *template.Template
+	contentType string
 
-	client *http.Client
+	queue *WebhookQueue
 }
 
 const (
 	WebhookEventConnecting    = "connect"
 	WebhookEventDisconnecting = "disconnect"
+	WebhookEventStatus        = "status"
+	WebhookEventDenied        = "denied"
+	WebhookEventStateChange   = "state-change"
 )
 
 const (
 	WebhookStatusMissingBackend          = "missing-backend"
 	WebhookStatusFailedBackendConnection = "failed-backend-connection"
 	WebhookStatusSuccess                 = "success"
+	WebhookStatusDenied                  = "denied"
+)
+
+// Event filters of webhook targets
+const (
+	// WebhookFilterStatus selects status requests, answered or passed through to the backend
+	WebhookFilterStatus = "status"
+	// WebhookFilterLogin selects join attempts while the server can't be joined
+	WebhookFilterLogin = "login"
+	// WebhookFilterConnect selects players connecting to and disconnecting from the backend
+	WebhookFilterConnect = "connect"
+	// WebhookFilterDenied selects players that were not allowed to wake the server
+	WebhookFilterDenied = "denied"
+	// WebhookFilterState selects changes of the server state
+	WebhookFilterState = "state"
 )
 
+var webhookFilters = []string{
+	WebhookFilterStatus, WebhookFilterLogin, WebhookFilterConnect, WebhookFilterDenied, WebhookFilterState,
+}
+
+// WebhookNotifierPayload is the body of webhook requests. Host is the virtual host handling the
+// event, "default" for the default configuration, and State the state of its server when the
+// event happened.
 type WebhookNotifierPayload struct {
+	// Id is unique for each event and also sent in the WebhookIdHeader
+	Id              string      `json:"id"`
 	Event           string      `json:"event"`
 	Timestamp       time.Time   `json:"timestamp"`
 	Status          string      `json:"status"`
 	Client          *ClientInfo `json:"client"`
 	Server          string      `json:"server"`
+	Host            string      `json:"host"`
 	PlayerInfo      *PlayerInfo `json:"player,omitempty"`
+	Transferred     bool        `json:"transferred,omitempty"`
 	BackendHostPort string      `json:"backend,omitempty"`
 	Error           string      `json:"error,omitempty"`
+	// Reason is one of the DeniedReason values for denied events
+	Reason        string      `json:"reason,omitempty"`
+	State         ServerState `json:"state"`
+	PreviousState ServerState `json:"previousState,omitempty"`
+}
+
+// NewWebhookNotifier creates a notifier sending the events of the host selected by the named
+// target to its URL, including the state reported by the manager
+func NewWebhookNotifier(host string, name string, target *WebhookTargetConfig, motdManager *MOTDManager, queue *WebhookQueue) (*WebhookNotifier, error) {
+	secret, err := target.secret()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read webhook secret: %w", err)
+	}
+	tmpl, err := target.template()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := webhookTimeout
+	if target.Timeout > 0 {
+		timeout = time.Duration(target.Timeout) * time.Second
+	}
+
+	w := &WebhookNotifier{
+		url:         target.Url,
+		headers:     target.Headers,
+		timeout:     timeout,
+		target:      webhookTarget(host, name),
+		signed:      len(secret) > 0,
+		template:    tmpl,
+		contentType: target.ContentType,
+		queue:       queue,
+	}
+	events := target.Events
+	if len(events) == 0 {
+		events = webhookFilters
+	}
+	w.payloadNotifier = newPayloadNotifier(host, events, motdManager, w.send)
+	queue.setSecret(w.target, secret)
+	return w, nil
 }
 
-func NewWebhookNotifier(url string, requireUser bool) *WebhookNotifier {
+// payloadNotifier implements ConnectionNotifier by building a WebhookNotifierPayload for each
+// event selected by its filters and passing it to deliver
+type payloadNotifier struct {
+	host        string
+	events      []string
+	motdManager *MOTDManager
+	deliver     func(ctx context.Context, payload *WebhookNotifierPayload) error
+}
 
-	return &WebhookNotifier{
-		url:         url,
-		requireUser: requireUser,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+func newPayloadNotifier(host string, events []string, motdManager *MOTDManager,
+	deliver func(ctx context.Context, payload *WebhookNotifierPayload) error) *payloadNotifier {
+	n := &payloadNotifier{
+		host:        host,
+		events:      make([]string, len(events)),
+		motdManager: motdManager,
+		deliver:     deliver,
 	}
+	for i, event := range events {
+		n.events[i] = strings.ToLower(event)
+	}
+	return n
+}
+
+// accepts reports whether the events of the filter are selected
+func (n *payloadNotifier) accepts(filter string) bool {
+	return slices.Contains(n.events, filter)
 }
 
-func (w *WebhookNotifier) NotifyMissingBackend(ctx context.Context, clientAddr net.Addr, server string, playerInfo *PlayerInfo) error {
-	if w.requireUser && playerInfo == nil {
+// acceptsConnection reports whether connections of the player are selected by the filter,
+// or by the status filter if there is no player
+func (n *payloadNotifier) acceptsConnection(playerInfo *PlayerInfo, filter string) bool {
+	if playerInfo == nil {
+		return n.accepts(WebhookFilterStatus)
+	}
+	return n.accepts(filter)
+}
+
+// notify completes the payload and delivers it
+func (n *payloadNotifier) notify(ctx context.Context, payload *WebhookNotifierPayload) error {
+	payload.Id = uuid.NewString()
+	payload.Host = n.host
+	if payload.State == "" {
+		payload.State = n.motdManager.GetCurrentState()
+	}
+	payload.Transferred = payload.PlayerInfo != nil && payload.PlayerInfo.Transferred
+	return n.deliver(ctx, payload)
+}
+
+func (n *payloadNotifier) NotifyMissingBackend(ctx context.Context, clientAddr net.Addr, server string, playerInfo *PlayerInfo) error {
+	if !n.acceptsConnection(playerInfo, WebhookFilterLogin) {
 		return nil
 	}
 
@@ -69,12 +190,12 @@ func (w *WebhookNotifier) NotifyMissingBackend(ctx context.Context, clientAddr n
 		Error:      "No backend found",
 	}
 
-	return w.send(ctx, payload)
+	return n.notify(ctx, payload)
 }
 
-func (w *WebhookNotifier) NotifyFailedBackendConnection(ctx context.Context, clientAddr net.Addr, server string,
+func (n *payloadNotifier) NotifyFailedBackendConnection(ctx context.Context, clientAddr net.Addr, server string,
 	playerInfo *PlayerInfo, backendHostPort string, err error) error {
-	if w.requireUser && playerInfo == nil {
+	if !n.acceptsConnection(playerInfo, WebhookFilterLogin) {
 		return nil
 	}
 
@@ -89,11 +210,11 @@ func (w *WebhookNotifier) NotifyFailedBackendConnection(ctx context.Context, cli
 		Error:           err.Error(),
 	}
 
-	return w.send(ctx, payload)
+	return n.notify(ctx, payload)
 }
 
-func (w *WebhookNotifier) NotifyConnected(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error {
-	if w.requireUser && playerInfo == nil {
+func (n *payloadNotifier) NotifyConnected(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error {
+	if !n.acceptsConnection(playerInfo, WebhookFilterConnect) {
 		return nil
 	}
 
@@ -107,11 +228,11 @@ func (w *WebhookNotifier) NotifyConnected(ctx context.Context, clientAddr net.Ad
 		BackendHostPort: backendHostPort,
 	}
 
-	return w.send(ctx, payload)
+	return n.notify(ctx, payload)
 }
 
-func (w *WebhookNotifier) NotifyDisconnected(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error {
-	if w.requireUser && playerInfo == nil {
+func (n *payloadNotifier) NotifyDisconnected(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, backendHostPort string) error {
+	if !n.acceptsConnection(playerInfo, WebhookFilterConnect) {
 		return nil
 	}
 
@@ -125,43 +246,86 @@ func (w *WebhookNotifier) NotifyDisconnected(ctx context.Context, clientAddr net
 		BackendHostPort: backendHostPort,
 	}
 
-	return w.send(ctx, payload)
+	return n.notify(ctx, payload)
 }
 
-func (w *WebhookNotifier) send(ctx context.Context, payload *WebhookNotifierPayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal webhook payload: %v", err)
+func (n *payloadNotifier) NotifyStatusRequest(ctx context.Context, clientAddr net.Addr, serverAddress string) error {
+	if !n.accepts(WebhookFilterStatus) {
+		return nil
 	}
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		w.url,
-		bytes.NewBuffer(jsonPayload),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create webhook request: %v", err)
+	payload := &WebhookNotifierPayload{
+		Event:     WebhookEventStatus,
+		Timestamp: time.Now(),
+		Status:    WebhookStatusSuccess,
+		Client:    ClientInfoFromAddr(clientAddr),
+		Server:    serverAddress,
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	return n.notify(ctx, payload)
+}
 
-	go func() {
-		resp, err := w.client.Do(req)
-		if err != nil {
-			// Handle error
-			log.Printf("Failed to send webhook notification: %v", err)
-			return
-		}
-		_ = resp.Body.Close()
+func (n *payloadNotifier) NotifyDenied(ctx context.Context, clientAddr net.Addr, serverAddress string, playerInfo *PlayerInfo, reason string) error {
+	if !n.accepts(WebhookFilterDenied) {
+		return nil
+	}
 
-		if resp.StatusCode >= 400 {
-			logrus.
-				WithField("status", resp.StatusCode).
-				Warn("webhook receiver responded with an error")
-		}
+	payload := &WebhookNotifierPayload{
+		Event:      WebhookEventDenied,
+		Timestamp:  time.Now(),
+		Status:     WebhookStatusDenied,
+		Client:     ClientInfoFromAddr(clientAddr),
+		Server:     serverAddress,
+		PlayerInfo: playerInfo,
+		Reason:     reason,
+	}
 
-	}()
+	return n.notify(ctx, payload)
+}
 
+func (n *payloadNotifier) NotifyStateChange(ctx context.Context, server string, previous, state ServerState) error {
+	if !n.accepts(WebhookFilterState) {
+		return nil
+	}
+
+	payload := &WebhookNotifierPayload{
+		Event:         WebhookEventStateChange,
+		Timestamp:     time.Now(),
+		Status:        WebhookStatusSuccess,
+		Server:        server,
+		State:         state,
+		PreviousState: previous,
+	}
+
+	return n.notify(ctx, payload)
+}
+
+func (w *WebhookNotifier) send(_ context.Context, payload *WebhookNotifierPayload) error {
+	body, err := w.render(payload)
+	if err != nil {
+		return err
+	}
+
+	request := newWebhookRequest(payload.Id, w.target, w.url, body, w.timeout)
+	request.Headers = maps.Clone(w.headers)
+	if request.Headers == nil {
+		request.Headers = make(map[string]string)
+	}
+	if w.contentType != "" {
+		request.Headers["Content-Type"] = w.contentType
+	}
+	w.queue.enqueue(request)
 	return nil
 }
+
+// render returns the request body for the payload
+func (w *WebhookNotifier) render(payload *WebhookNotifierPayload) ([]byte, error) {
+	if w.template != nil {
+		return renderWebhookTemplate(w.template, payload)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,343 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Reasons webhook events are dropped
+const (
+	webhookDropQueueFull        = "queue_full"
+	webhookDropRejected         = "rejected"
+	webhookDropRetriesExhausted = "retries_exhausted"
+)
+
+// webhookRequest is a webhook event ready to be delivered, as kept in the queue directory
+type webhookRequest struct {
+	Id      string            `json:"id"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Target names the host and target the request is signed for, see WebhookQueue.secrets
+	Target   string        `json:"target"`
+	Body     []byte        `json:"body"`
+	Timeout  time.Duration `json:"timeout"`
+	Created  time.Time     `json:"created"`
+	Attempts int           `json:"attempts"`
+}
+
+// deadLetter is a line of the dead-letter file
+type deadLetter struct {
+	*webhookRequest
+	// Body is kept readable rather than base64 encoded
+	Body    string    `json:"body"`
+	Dropped time.Time `json:"dropped"`
+	Reason  string    `json:"reason"`
+	Error   string    `json:"error"`
+}
+
+// webhookStatusError is returned for responses with an error status
+type webhookStatusError struct {
+	status int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook receiver responded with status %d", e.status)
+}
+
+// retryable reports whether the request may succeed when sent again
+func (e *webhookStatusError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusTooManyRequests || e.status == http.StatusRequestTimeout
+}
+
+// WebhookQueue delivers webhook requests in the background, retrying failed ones with
+// exponential backoff. With a queue directory, requests are kept on disk until they are
+// delivered so that they survive a restart. It is kept across configuration reloads.
+type WebhookQueue struct {
+	ctx    context.Context
+	client *http.Client
+	dir    string
+	config atomic.Pointer[WebhookQueueConfig]
+
+	pending      atomic.Int64
+	deadLetterMu sync.Mutex
+
+	// secrets holds the current secret of each target, so that secrets aren't written to the
+	// queue directory and requests are signed with the secret configured when they are sent
+	secretsMu sync.RWMutex
+	secrets   map[string][]byte
+}
+
+// NewWebhookQueue creates the queue. The requests left in the queue directory by a previous run
+// are delivered once the targets are known, by calling replay.
+func NewWebhookQueue(ctx context.Context, config *WebhookQueueConfig) (*WebhookQueue, error) {
+	q := &WebhookQueue{
+		ctx:     ctx,
+		client:  &http.Client{},
+		dir:     config.Dir,
+		secrets: make(map[string][]byte),
+	}
+	q.config.Store(config)
+
+	if q.dir != "" {
+		if err := os.MkdirAll(q.dir, 0o755); err != nil {
+			return nil, fmt.Errorf("unable to create webhook queue directory: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// webhookTarget returns the name requests of the target of the host are queued with
+func webhookTarget(host, target string) string {
+	return host + "/" + target
+}
+
+// setSecret records the current secret of the target, which is empty if requests aren't signed
+func (q *WebhookQueue) setSecret(target string, secret []byte) {
+	q.secretsMu.Lock()
+	defer q.secretsMu.Unlock()
+	q.secrets[target] = secret
+}
+
+func (q *WebhookQueue) secret(target string) []byte {
+	q.secretsMu.RLock()
+	defer q.secretsMu.RUnlock()
+	return q.secrets[target]
+}
+
+// configure applies a reloaded configuration. The queue directory can't be changed.
+func (q *WebhookQueue) configure(config *WebhookQueueConfig) {
+	if config.Dir != q.dir {
+		logrus.
+			WithField("dir", q.dir).
+			WithField("newDir", config.Dir).
+			Warn("Changing the webhook queue directory requires a restart, continuing to use the current one")
+	}
+	q.config.Store(config)
+}
+
+// newWebhookRequest creates a request for a new webhook event with its unique ID
+func newWebhookRequest(id string, target string, url string, body []byte, timeout time.Duration) *webhookRequest {
+	return &webhookRequest{
+		Id:      id,
+		Target:  target,
+		Url:     url,
+		Body:    body,
+		Timeout: timeout,
+		Created: time.Now(),
+	}
+}
+
+// enqueue delivers the request in the background
+func (q *WebhookQueue) enqueue(request *webhookRequest) {
+	if q.pending.Add(1) > int64(q.config.Load().Size) {
+		q.pending.Add(-1)
+		q.drop(request, webhookDropQueueFull, errors.New("too many webhook events waiting for delivery"))
+		return
+	}
+
+	if err := q.save(request); err != nil {
+		logrus.WithError(err).WithField("id", request.Id).Warn("Unable to keep webhook event in the queue directory")
+	}
+	go q.deliver(request)
+}
+
+// replay delivers the requests found in the queue directory
+func (q *WebhookQueue) replay() error {
+	if q.dir == "" {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read queued webhook event: %w", err)
+		}
+		request := &webhookRequest{}
+		if err := json.Unmarshal(data, request); err != nil {
+			logrus.WithError(err).WithField("file", path).Warn("Ignoring invalid queued webhook event")
+			continue
+		}
+
+		q.pending.Add(1)
+		go q.deliver(request)
+	}
+
+	if len(paths) > 0 {
+		logrus.WithField("count", len(paths)).Info("Delivering webhook events left from the previous run")
+	}
+	return nil
+}
+
+// deliver sends the request until it succeeds, fails for good or runs out of retries. If the
+// queue is stopped meanwhile, the request stays in the queue directory for the next run.
+func (q *WebhookQueue) deliver(request *webhookRequest) {
+	defer q.pending.Add(-1)
+
+	for {
+		err := q.send(request)
+		if err == nil {
+			q.remove(request)
+			return
+		}
+		if q.ctx.Err() != nil {
+			return
+		}
+
+		var statusErr *webhookStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			q.drop(request, webhookDropRejected, err)
+			q.remove(request)
+			return
+		}
+
+		config := q.config.Load()
+		if request.Attempts > config.MaxRetries {
+			q.drop(request, webhookDropRetriesExhausted, err)
+			q.remove(request)
+			return
+		}
+
+		delay := retryDelay(request.Attempts, config)
+		logrus.
+			WithError(err).
+			WithField("id", request.Id).
+			WithField("attempt", request.Attempts).
+			WithField("retryIn", delay).
+			Warn("Failed to send webhook notification, retrying")
+
+		if err := q.save(request); err != nil {
+			logrus.WithError(err).WithField("id", request.Id).Warn("Unable to keep webhook event in the queue directory")
+		}
+
+		select {
+		case <-q.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay doubles the delay with each attempt up to the maximum and picks a random delay
+// between half of it and all of it, so that retries of many events don't arrive at once
+func retryDelay(attempts int, config *WebhookQueueConfig) time.Duration {
+	delay := time.Duration(config.RetryDelay) * time.Second
+	maxDelay := time.Duration(config.MaxRetryDelay) * time.Second
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func (q *WebhookQueue) send(request *webhookRequest) error {
+	request.Attempts++
+
+	ctx, cancel := context.WithTimeout(q.ctx, request.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, request.Url, bytes.NewReader(request.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range request.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range webhookHeaders(q.secret(request.Target), request.Id, time.Now(), request.Body) {
+		req.Header.Set(name, value)
+	}
+
+	start := time.Now()
+	resp, err := q.client.Do(req)
+	if err != nil {
+		observeWebhookRequest(start, false)
+		return err
+	}
+	_ = resp.Body.Close()
+	observeWebhookRequest(start, resp.StatusCode < 400)
+
+	if resp.StatusCode >= 400 {
+		return &webhookStatusError{status: resp.StatusCode}
+	}
+	return nil
+}
+
+// drop gives up on the request, counting it and appending it to the dead-letter file
+func (q *WebhookQueue) drop(request *webhookRequest, reason string, err error) {
+	webhookEventsDroppedTotal.WithLabelValues(reason).Inc()
+	logrus.
+		WithError(err).
+		WithField("id", request.Id).
+		WithField("url", request.Url).
+		WithField("attempts", request.Attempts).
+		WithField("reason", reason).
+		Error("Dropped webhook notification")
+
+	path := q.config.Load().DeadLetterFile
+	if path == "" {
+		return
+	}
+	line, marshalErr := json.Marshal(&deadLetter{
+		webhookRequest: request,
+		Body:           string(request.Body),
+		Dropped:        time.Now(),
+		Reason:         reason,
+		Error:          err.Error(),
+	})
+	if marshalErr != nil {
+		return
+	}
+
+	q.deadLetterMu.Lock()
+	defer q.deadLetterMu.Unlock()
+	file, openErr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if openErr == nil {
+		_, openErr = file.Write(append(line, '\n'))
+		if closeErr := file.Close(); openErr == nil {
+			openErr = closeErr
+		}
+	}
+	if openErr != nil {
+		logrus.WithError(openErr).WithField("file", path).Warn("Unable to write webhook event to the dead-letter file")
+	}
+}
+
+func (q *WebhookQueue) requestPath(request *webhookRequest) string {
+	return filepath.Join(q.dir, request.Id+".json")
+}
+
+func (q *WebhookQueue) save(request *webhookRequest) error {
+	if q.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.requestPath(request), data)
+}
+
+func (q *WebhookQueue) remove(request *webhookRequest) {
+	if q.dir == "" {
+		return
+	}
+	if err := os.Remove(q.requestPath(request)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logrus.WithError(err).WithField("id", request.Id).Warn("Unable to remove delivered webhook event from the queue directory")
+	}
+}
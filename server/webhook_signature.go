@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with each webhook request. The signature header is only sent when a webhook
+// secret is configured.
+const (
+	WebhookIdHeader        = "X-Mc-Motd-Id"
+	WebhookTimestampHeader = "X-Mc-Motd-Timestamp"
+	WebhookSignatureHeader = "X-Mc-Motd-Signature"
+)
+
+// webhookSignaturePrefix names the algorithm in the signature header
+const webhookSignaturePrefix = "sha256="
+
+var (
+	// ErrWebhookSignature is returned by VerifyWebhookSignature when the signature is missing
+	// or doesn't match the body
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+	// ErrWebhookTimestamp is returned by VerifyWebhookSignature when the timestamp is missing
+	// or outside the tolerance
+	ErrWebhookTimestamp = errors.New("invalid webhook timestamp")
+)
+
+// SignWebhook returns the value of the signature header for a webhook request body sent at the
+// given unix timestamp: the hex encoded HMAC-SHA256 of the timestamp, a dot and the body.
+func SignWebhook(secret []byte, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookHeaders returns the headers identifying the webhook event and, with a secret, signing
+// its body as sent at the given time. Each attempt is signed again, so only the event ID stays
+// the same across retries.
+func webhookHeaders(secret []byte, id string, sent time.Time, body []byte) map[string]string {
+	timestamp := sent.Unix()
+	headers := map[string]string{
+		WebhookIdHeader:        id,
+		WebhookTimestampHeader: strconv.FormatInt(timestamp, 10),
+	}
+	if len(secret) > 0 {
+		headers[WebhookSignatureHeader] = SignWebhook(secret, timestamp, body)
+	}
+	return headers
+}
+
+// VerifyWebhookSignature checks that a webhook request was signed with the shared secret and
+// was sent no longer than tolerance ago, where a tolerance of zero skips the timestamp check.
+//
+// To protect against replayed requests, receivers should also remember the event IDs given
+// by the WebhookIdHeader within the tolerance and ignore events they've seen before.
+func VerifyWebhookSignature(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil {
+		return ErrWebhookTimestamp
+	}
+
+	signature := header.Get(WebhookSignatureHeader)
+	if !strings.HasPrefix(signature, webhookSignaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(SignWebhook(secret, timestamp, body))) {
+		return ErrWebhookSignature
+	}
+
+	if tolerance > 0 {
+		age := time.Since(time.Unix(timestamp, 0))
+		if age > tolerance || age < -tolerance {
+			return fmt.Errorf("%w: sent %s ago", ErrWebhookTimestamp, age.Round(time.Second))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Built-in webhook templates
+const (
+	WebhookPresetDiscord = "discord"
+	WebhookPresetSlack   = "slack"
+	WebhookPresetTeams   = "teams"
+)
+
+// webhookPresets are the bodies of Discord embeds, Slack blocks and Teams adaptive cards
+var webhookPresets = map[string]string{
+	WebhookPresetDiscord: `{"embeds":[{"title":{{json .Summary}},"timestamp":{{json .Timestamp}},"fields":[` +
+		`{"name":"Server","value":{{json .Server}},"inline":true},` +
+		`{"name":"State","value":{{json .State}},"inline":true}` +
+		`{{if .Player}},{"name":"Player","value":{{json .Player}},"inline":true}{{end}}]}]}`,
+
+	WebhookPresetSlack: `{"text":{{json .Summary}},"blocks":[` +
+		`{"type":"section","text":{"type":"mrkdwn","text":{{json .Summary}}}},` +
+		`{"type":"context","elements":[{"type":"mrkdwn","text":{{json (printf "%s · %s" .Server .State)}}}]}]}`,
+
+	WebhookPresetTeams: `{"type":"message","attachments":[{"contentType":"application/vnd.microsoft.card.adaptive","content":{` +
+		`"$schema":"http://adaptivecards.io/schemas/adaptive-card.json","type":"AdaptiveCard","version":"1.4","body":[` +
+		`{"type":"TextBlock","text":{{json .Summary}},"weight":"Bolder","wrap":true},` +
+		`{"type":"FactSet","facts":[{"title":"Server","value":{{json .Server}}},{"title":"State","value":{{json .State}}}` +
+		`{{if .Player}},{"title":"Player","value":{{json .Player}}}{{end}}]}]}}]}`,
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes the value, so that strings are quoted and escaped
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// WebhookTemplateData is passed to webhook templates. Besides the fields of the payload, it
+// gives the player name and client IP directly, empty if there are none.
+type WebhookTemplateData struct {
+	*WebhookNotifierPayload
+	Player   string
+	ClientIp string
+}
+
+func newWebhookTemplateData(payload *WebhookNotifierPayload) *WebhookTemplateData {
+	data := &WebhookTemplateData{WebhookNotifierPayload: payload}
+	if payload.PlayerInfo != nil {
+		data.Player = payload.PlayerInfo.Name
+	}
+	if payload.Client != nil {
+		data.ClientIp = payload.Client.Host
+	}
+	return data
+}
+
+// Summary describes the event in a sentence
+func (d *WebhookTemplateData) Summary() string {
+	who := d.Player
+	if who == "" {
+		who = d.ClientIp
+	}
+
+	switch d.Event {
+	case WebhookEventStateChange:
+		return fmt.Sprintf("%s is now %s", d.Host, d.State)
+	case WebhookEventStatus:
+		return fmt.Sprintf("%s checked the status of %s", who, d.Server)
+	case WebhookEventDenied:
+		return fmt.Sprintf("%s was not allowed to wake %s (%s)", who, d.Server, d.Reason)
+	case WebhookEventDisconnecting:
+		return fmt.Sprintf("%s left %s", who, d.Server)
+	}
+
+	switch {
+	case d.Player == "":
+		return fmt.Sprintf("%s checked the status of %s", who, d.Server)
+	case d.Status == WebhookStatusSuccess:
+		return fmt.Sprintf("%s joined %s", who, d.Server)
+	case d.Status == WebhookStatusMissingBackend:
+		return fmt.Sprintf("%s tried to join %s, which has no backend", who, d.Server)
+	default:
+		return fmt.Sprintf("%s tried to join %s, waking it up", who, d.Server)
+	}
+}
+
+// template returns the template of the request body or nil if the JSON payload is sent
+func (w *WebhookTargetConfig) template() (*template.Template, error) {
+	text := w.Template
+	if w.Preset != "" {
+		if w.Template != "" {
+			return nil, fmt.Errorf("a template and a preset can't both be given")
+		}
+		preset, exists := webhookPresets[strings.ToLower(w.Preset)]
+		if !exists {
+			return nil, fmt.Errorf("unknown preset %q", w.Preset)
+		}
+		text = preset
+	}
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("webhook").Funcs(webhookTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderWebhookTemplate executes the template for the payload
+func renderWebhookTemplate(tmpl *template.Template, payload *WebhookNotifierPayload) ([]byte, error) {
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, newWebhookTemplateData(payload)); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return body.Bytes(), nil
+}